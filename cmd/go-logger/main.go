@@ -3,14 +3,21 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/adiyakaihsan/go-logger/pkg/app"
+	"github.com/adiyakaihsan/go-logger/pkg/auth"
+	"github.com/adiyakaihsan/go-logger/pkg/shipper"
 	"github.com/spf13/cobra"
 )
 
 var (
 	serverCount int
 	serverPort  int
+	gelfUDPAddr string
+	gelfTCPAddr string
+	configFile  string
+	authKeys    string
 )
 
 func main() {
@@ -28,8 +35,53 @@ func main() {
 
 	runCmd.Flags().IntVarP(&serverCount, "count", "c", 1, "Number of servers to run")
 	runCmd.Flags().IntVarP(&serverPort, "port", "p", 8080, "Init Port number")
+	runCmd.Flags().StringVar(&gelfUDPAddr, "gelf-udp", "", "Address for the GELF UDP input, e.g. :12201 (disabled if empty)")
+	runCmd.Flags().StringVar(&gelfTCPAddr, "gelf-tcp", "", "Address for the GELF TCP input, e.g. :12201 (disabled if empty)")
+	runCmd.Flags().StringVar(&configFile, "config", "", "Path to a JSON config file with pipelines and sources")
+	runCmd.Flags().StringVar(&authKeys, "auth-keys", "", "Path to the API key file; the API is open if empty")
+	runCmd.Flags().String("tls-cert", "", "TLS certificate file; serves plain HTTP if empty")
+	runCmd.Flags().String("tls-key", "", "TLS private key file")
+	runCmd.Flags().String("tls-client-ca", "", "CA file used to verify client certificates (mTLS)")
+	runCmd.Flags().Bool("tls-require-client-cert", false, "Require a verified client certificate for ingest")
+	runCmd.Flags().Int("grpc-port", 0, "Port for the gRPC API (disabled if 0)")
 	rootCmd.AddCommand(runCmd)
 
+	shipCmd := &cobra.Command{
+		Use:   "ship",
+		Short: "Tail log files and ship them to a go-logger server",
+		Run:   shipper.Run,
+	}
+
+	shipCmd.Flags().StringSlice("path", nil, "Glob of files to tail, can be repeated")
+	shipCmd.Flags().String("format", "", "Format of lines from --path: json (default), raw, logfmt, combined, cri or cef")
+	shipCmd.Flags().String("config", "", "Path to a JSON config file with shipper sources")
+	shipCmd.Flags().String("target", "http://localhost:8080", "go-logger server or proxy URL")
+	shipCmd.Flags().String("registry", "shipper-registry.json", "File to persist read offsets in")
+	shipCmd.Flags().Int("batch-size", 500, "Maximum lines per batch")
+	shipCmd.Flags().Duration("flush-interval", 5*time.Second, "Maximum time a line waits before being sent")
+	shipCmd.Flags().Duration("scan-interval", time.Second, "How often files are polled and globs re-evaluated")
+	shipCmd.Flags().String("api-key", "", "API key sent to the target")
+	shipCmd.Flags().String("tls-ca", "", "CA file to verify the target's certificate")
+	shipCmd.Flags().String("tls-cert", "", "Client certificate file for mTLS")
+	shipCmd.Flags().String("tls-key", "", "Client private key file for mTLS")
+	rootCmd.AddCommand(shipCmd)
+
+	keysCmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage API keys",
+	}
+	createKeyCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key and print its token",
+		Run:   auth.CreateKey,
+	}
+	createKeyCmd.Flags().String("file", "api-keys.json", "API key file")
+	createKeyCmd.Flags().String("name", "", "Unique name of the key")
+	createKeyCmd.Flags().String("role", string(auth.RoleIngest), "Role of the key: ingest, read or admin")
+	createKeyCmd.Flags().String("tenant", "", "Tenant the key is bound to (optional)")
+	keysCmd.AddCommand(createKeyCmd)
+	rootCmd.AddCommand(keysCmd)
+
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatalf("Error: %v", err)
 		os.Exit(1)
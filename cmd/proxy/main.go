@@ -1,9 +1,42 @@
 package main
 
 import (
+	"flag"
+	"strings"
+
 	"github.com/adiyakaihsan/go-logger/pkg/proxy"
+	"github.com/adiyakaihsan/go-logger/pkg/tlsconfig"
 )
 
 func main() {
-	proxy.Run()
+	port := flag.String("port", "8256", "Port to listen on")
+	backends := flag.String("backends", "http://localhost:8083,http://localhost:8082", "Comma separated go-logger backends")
+	authKeys := flag.String("auth-keys", "", "Path to the API key file; the proxy is open if empty")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file; serves plain HTTP if empty")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
+	tlsClientCA := flag.String("tls-client-ca", "", "CA file used to verify client certificates (mTLS)")
+	requireClientCert := flag.Bool("tls-require-client-cert", false, "Require a verified client certificate for ingest")
+	backendCA := flag.String("backend-ca", "", "CA file to verify backend certificates")
+	backendCert := flag.String("backend-cert", "", "Client certificate presented to backends")
+	backendKey := flag.String("backend-key", "", "Client private key presented to backends")
+	backendInsecure := flag.Bool("backend-insecure", false, "Skip verification of backend certificates")
+	flag.Parse()
+
+	proxy.Run(proxy.Config{
+		Port:         *port,
+		Backends:     strings.Split(*backends, ","),
+		AuthKeysFile: *authKeys,
+		TLS: tlsconfig.ServerOptions{
+			CertFile:     *tlsCert,
+			KeyFile:      *tlsKey,
+			ClientCAFile: *tlsClientCA,
+		},
+		BackendTLS: tlsconfig.ClientOptions{
+			CAFile:             *backendCA,
+			CertFile:           *backendCert,
+			KeyFile:            *backendKey,
+			InsecureSkipVerify: *backendInsecure,
+		},
+		RequireClientCert: *requireClientCert,
+	})
 }
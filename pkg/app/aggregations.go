@@ -0,0 +1,291 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+const (
+	defaultTermsSize     = 10
+	maxTermsSize         = 1000
+	autoHistogramBuckets = 60
+	maxHistogramBuckets  = 1000
+	maxAggregationDepth  = 2
+	// maxNestedTermsSize caps terms aggregations with sub-aggregations,
+	// which run one search per bucket.
+	maxNestedTermsSize = 50
+)
+
+// autoIntervals are the date histogram intervals picked by "auto".
+var autoIntervals = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+	24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+type bucketDef struct {
+	key        string
+	start, end time.Time
+	from, to   *float64
+}
+
+type aggregationPlan struct {
+	agg      types.Aggregation
+	field    string
+	interval time.Duration
+	buckets  []bucketDef
+	sub      map[string]*aggregationPlan
+}
+
+// timeBounds returns the time range histogram buckets must cover.
+type timeBounds func() (time.Time, time.Time, error)
+
+// planAggregations validates aggs and computes their buckets.
+func planAggregations(aggs map[string]types.Aggregation, bounds timeBounds, depth int) (map[string]*aggregationPlan, error) {
+	if len(aggs) == 0 {
+		return nil, nil
+	}
+	if depth >= maxAggregationDepth {
+		return nil, fmt.Errorf("aggregations can be nested at most %d deep", maxAggregationDepth)
+	}
+
+	plans := map[string]*aggregationPlan{}
+	for name, agg := range aggs {
+		if agg.Field == "" {
+			agg.Field = "timestamp"
+		}
+		plan := &aggregationPlan{agg: agg, field: types.IndexField(agg.Field)}
+
+		var err error
+		switch agg.Type {
+		case types.AggregationTerms:
+			if agg.Size <= 0 {
+				plan.agg.Size = defaultTermsSize
+			}
+			plan.agg.Size = min(plan.agg.Size, maxTermsSize)
+		case types.AggregationDateHistogram:
+			err = plan.planHistogram(bounds)
+		case types.AggregationRange:
+			err = plan.planRanges()
+		default:
+			err = fmt.Errorf("unknown type %q", agg.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("aggregation %q: %w", name, err)
+		}
+
+		if len(agg.Aggregations) > 0 {
+			if agg.Type != types.AggregationTerms {
+				return nil, fmt.Errorf("aggregation %q: only terms aggregations can be nested", name)
+			}
+			plan.agg.Size = min(plan.agg.Size, maxNestedTermsSize)
+			plan.sub, err = planAggregations(agg.Aggregations, bounds, depth+1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		plans[name] = plan
+	}
+	return plans, nil
+}
+
+func (p *aggregationPlan) planHistogram(bounds timeBounds) error {
+	start, end, err := bounds()
+	if err != nil {
+		return err
+	}
+	if start.IsZero() || !end.After(start) {
+		return nil
+	}
+
+	switch p.agg.Interval {
+	case "", "auto":
+		p.interval = autoIntervals[len(autoIntervals)-1]
+		for _, interval := range autoIntervals {
+			if end.Sub(start)/interval < autoHistogramBuckets {
+				p.interval = interval
+				break
+			}
+		}
+	default:
+		p.interval, err = parseInterval(p.agg.Interval)
+		if err != nil {
+			return err
+		}
+	}
+
+	for bucket := start.Truncate(p.interval); bucket.Before(end); bucket = bucket.Add(p.interval) {
+		if len(p.buckets) == maxHistogramBuckets {
+			return fmt.Errorf("interval %v gives more than %d buckets", p.interval, maxHistogramBuckets)
+		}
+		p.buckets = append(p.buckets, bucketDef{
+			key:   bucket.UTC().Format(time.RFC3339),
+			start: bucket,
+			end:   bucket.Add(p.interval),
+		})
+	}
+	return nil
+}
+
+// parseInterval accepts Go durations and whole days such as "1d".
+func parseInterval(value string) (time.Duration, error) {
+	var interval time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		interval = time.Duration(n) * 24 * time.Hour
+	} else {
+		interval, err = time.ParseDuration(value)
+	}
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid interval %q", value)
+	}
+	return interval, nil
+}
+
+func (p *aggregationPlan) planRanges() error {
+	if len(p.agg.Ranges) == 0 {
+		return fmt.Errorf("ranges are required")
+	}
+	seen := map[string]bool{}
+	for _, r := range p.agg.Ranges {
+		if r.From == nil && r.To == nil {
+			return fmt.Errorf("range needs from or to")
+		}
+		key := r.Name
+		if key == "" {
+			key = rangeKey(r.From) + "-" + rangeKey(r.To)
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate range %q", key)
+		}
+		seen[key] = true
+		p.buckets = append(p.buckets, bucketDef{key: key, from: r.From, to: r.To})
+	}
+	return nil
+}
+
+func rangeKey(bound *float64) string {
+	if bound == nil {
+		return "*"
+	}
+	return strconv.FormatFloat(*bound, 'f', -1, 64)
+}
+
+// facet returns the facet computing the plan, or nil when it has nothing to
+// count.
+func (p *aggregationPlan) facet() *bleve.FacetRequest {
+	switch p.agg.Type {
+	case types.AggregationTerms:
+		return bleve.NewFacetRequest(p.field, p.agg.Size)
+	case types.AggregationDateHistogram:
+		if len(p.buckets) == 0 {
+			return nil
+		}
+		facet := bleve.NewFacetRequest(p.field, len(p.buckets))
+		for _, b := range p.buckets {
+			facet.AddDateTimeRange(b.key, b.start, b.end)
+		}
+		return facet
+	default:
+		facet := bleve.NewFacetRequest(p.field, len(p.buckets))
+		for _, b := range p.buckets {
+			facet.AddNumericRange(b.key, b.from, b.to)
+		}
+		return facet
+	}
+}
+
+func addFacets(req *bleve.SearchRequest, plans map[string]*aggregationPlan) {
+	for name, plan := range plans {
+		if facet := plan.facet(); facet != nil {
+			req.AddFacet(name, facet)
+		}
+	}
+}
+
+// result converts a facet into buckets. Histogram and range buckets keep
+// their order and include empty buckets.
+func (p *aggregationPlan) result(facet *search.FacetResult) types.AggregationResult {
+	result := types.AggregationResult{
+		Type:    p.agg.Type,
+		Field:   p.agg.Field,
+		Buckets: []types.Bucket{},
+	}
+	if p.interval > 0 {
+		result.Interval = p.interval.String()
+	}
+	if facet == nil {
+		for _, b := range p.buckets {
+			result.Buckets = append(result.Buckets, types.Bucket{Key: b.key})
+		}
+		return result
+	}
+	result.Missing = facet.Missing
+
+	if p.agg.Type == types.AggregationTerms {
+		result.Other = facet.Other
+		if facet.Terms != nil {
+			for _, term := range facet.Terms.Terms() {
+				result.Buckets = append(result.Buckets, types.Bucket{Key: term.Term, Count: term.Count})
+			}
+		}
+		return result
+	}
+
+	counts := map[string]int{}
+	for _, r := range facet.DateRanges {
+		counts[r.Name] = r.Count
+	}
+	for _, r := range facet.NumericRanges {
+		counts[r.Name] = r.Count
+	}
+	for _, b := range p.buckets {
+		result.Buckets = append(result.Buckets, types.Bucket{Key: b.key, Count: counts[b.key]})
+	}
+	return result
+}
+
+// aggregationResults reads the facets of a search back into results and
+// runs one search per term bucket for nested aggregations.
+func (ilm *IndexLifecycleManager) aggregationResults(ctx context.Context, q query.Query, plans map[string]*aggregationPlan, facets search.FacetResults) (map[string]types.AggregationResult, error) {
+	if len(plans) == 0 {
+		return nil, nil
+	}
+
+	results := map[string]types.AggregationResult{}
+	for name, plan := range plans {
+		result := plan.result(facets[name])
+		for i, bucket := range result.Buckets {
+			if len(plan.sub) == 0 {
+				break
+			}
+			term := bleve.NewTermQuery(bucket.Key)
+			term.SetField(plan.field)
+			bucketQuery := bleve.NewConjunctionQuery(q, term)
+
+			req := bleve.NewSearchRequestOptions(bucketQuery, 0, 0, false)
+			addFacets(req, plan.sub)
+			sub, err := ilm.indexSearch.SearchInContext(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			result.Buckets[i].Aggregations, err = ilm.aggregationResults(ctx, bucketQuery, plan.sub, sub.Facets)
+			if err != nil {
+				return nil, err
+			}
+		}
+		results[name] = result
+	}
+	return results, nil
+}
@@ -9,14 +9,37 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/gelf"
+	"github.com/adiyakaihsan/go-logger/pkg/multiline"
+	"github.com/adiyakaihsan/go-logger/pkg/pipeline"
 	"github.com/adiyakaihsan/go-logger/pkg/queue"
+	"github.com/adiyakaihsan/go-logger/pkg/ratelimit"
+	"github.com/adiyakaihsan/go-logger/pkg/redact"
+	"github.com/adiyakaihsan/go-logger/pkg/sampling"
+	"github.com/adiyakaihsan/go-logger/pkg/tlsconfig"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
 	"github.com/spf13/cobra"
 )
 
 type App struct {
 	queue     queue.Queue
-	ilm       *IndexLifecycleManager
+	tenants   *TenantManager
 	processor *LogProcessor
+	gelf      *gelf.Server
+	pipelines *pipeline.Manager
+	redactor  *redact.Redactor
+	sources   map[string]config.SourceConfig
+	multiline map[string]*multiline.Aggregator
+	batches   *recentBatches
+	limiter   *ratelimit.Limiter
+	sampler   *sampling.Sampler
+	tail      *tailHub
+	searches  *searchLimits
+	// allowedOrigins may open tail WebSockets besides the server's own.
+	allowedOrigins []string
+	// done is closed when the server shuts down, ending live tails.
+	done chan struct{}
 }
 
 type Config struct {
@@ -24,32 +47,135 @@ type Config struct {
 	RetentionDays time.Duration
 	ShutdownTimer time.Duration
 	Port          string
+	GelfUDPAddr   string
+	GelfTCPAddr   string
+	ConfigFile    string
+	AuthKeysFile  string
+	TLS           tlsconfig.ServerOptions
+	// RequireClientCert restricts ingest to clients presenting a certificate
+	// signed by TLS.ClientCAFile.
+	RequireClientCert bool
+	// GRPCPort serves the gRPC API when set.
+	GRPCPort string
 }
 
 func NewApp(cfg Config) (*App, error) {
+	fileCfg, err := config.Load(cfg.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
 	logQueue, err := queue.NewNatsQueue("nats://localhost:4222", "log", "logQueue", true)
 	if err != nil {
 		log.Fatalf("Failed to initiate channel. Error: %v", err)
 	}
 
-	ilm, err := NewIndexLifecycleManager(cfg.IndexName, cfg.RetentionDays)
+	app, err := NewAppWithQueue(cfg, fileCfg, logQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.GelfUDPAddr != "" || cfg.GelfTCPAddr != "" {
+		app.gelf = gelf.NewServer(cfg.GelfUDPAddr, cfg.GelfTCPAddr, func(logs types.LogFormat) error {
+			logs.Tenant = fileCfg.Sources["gelf"].Tenant
+			if app.limiter != nil {
+				key := app.limitKey("", "gelf", logs.Tenant)
+				if d := app.limiter.Allow(key, 1, int64(len(logs.Message))); !d.Allowed {
+					return fmt.Errorf("dropped log from %v: %s", key, d.Reason)
+				}
+			}
+			return app.enqueue("gelf", logs)
+		})
+	}
+
+	return app, nil
+}
+
+// NewAppWithQueue builds an App on logQueue from an already loaded config,
+// without network inputs. It is used to embed go-logger in-process.
+func NewAppWithQueue(cfg Config, fileCfg *config.File, logQueue queue.Queue) (*App, error) {
+	pipelines, err := pipeline.NewManager(fileCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipeline config: %w", err)
+	}
+
+	redactor, err := redact.New(fileCfg.Redaction)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redaction config: %w", err)
+	}
+
+	limiter, err := ratelimit.New(fileCfg.RateLimit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit config: %w", err)
+	}
+
+	sampler, err := sampling.New(fileCfg.Sampling)
 	if err != nil {
-		log.Fatalf("Failed to initiate index. Error: %v", err)
+		return nil, fmt.Errorf("invalid sampling config: %w", err)
 	}
 
-	processor := NewLogProcessor(logQueue, ilm)
+	searches, err := newSearchLimits(fileCfg.Search)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search config: %w", err)
+	}
+
+	tenants, err := NewTenantManager(cfg.IndexName, cfg.RetentionDays, fileCfg.Tenants, fileCfg.Index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate index: %w", err)
+	}
+
+	tail, err := newTailHub(newIndexMapping(fileCfg.Index))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate tail: %w", err)
+	}
+
+	processor := NewLogProcessor(logQueue, tenants, redactor)
 
 	app := &App{
 		queue:     logQueue,
-		ilm:       ilm,
+		tenants:   tenants,
 		processor: processor,
+		pipelines: pipelines,
+		redactor:  redactor,
+		sources:   fileCfg.Sources,
+		multiline: map[string]*multiline.Aggregator{},
+		batches:   newRecentBatches(),
+		limiter:   limiter,
+		sampler:   sampler,
+		tail:      tail,
+		searches:  searches,
+
+		allowedOrigins: fileCfg.Tail.AllowedOrigins,
+		done:           make(chan struct{}),
+	}
+	processor.AfterIndex(func(id string, logs types.LogFormat, err error) {
+		if err == nil {
+			app.tail.publish(indexedLog{id: id, logs: logs})
+		}
+	})
+
+	for source, sc := range fileCfg.Sources {
+		if sc.Multiline == nil {
+			continue
+		}
+		aggregator, err := multiline.New(*sc.Multiline, func(_ string, logs types.LogFormat, ref interface{}) error {
+			err := app.process(ref.(string), logs)
+			if err != nil {
+				log.Printf("Cannot enqueue logs. Error: %v", err)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("source %v: %w", source, err)
+		}
+		app.multiline[source] = aggregator
 	}
 
 	return app, nil
 }
 
 func (a *App) Start() error {
-	if err := a.ilm.StartScheduler(); err != nil {
+	if err := a.tenants.StartScheduler(); err != nil {
 		log.Printf("Error starting ILM Scheduler. Error: %v", err)
 	}
 
@@ -57,19 +183,61 @@ func (a *App) Start() error {
 		return fmt.Errorf("failed to start log processor: %w", err)
 	}
 
+	for _, aggregator := range a.multiline {
+		aggregator.Start()
+	}
+
+	if a.limiter != nil {
+		a.limiter.Start()
+	}
+
+	if a.gelf != nil {
+		if err := a.gelf.Start(); err != nil {
+			return fmt.Errorf("failed to start gelf input: %w", err)
+		}
+	}
+
 	return nil
 }
 
 func (a *App) Shutdown() error {
-	a.ilm.StopScheduler()
+	a.endStreams()
+	a.tenants.StopScheduler()
+	if a.gelf != nil {
+		a.gelf.Shutdown()
+	}
+	for _, aggregator := range a.multiline {
+		aggregator.Close()
+	}
+	if a.limiter != nil {
+		if err := a.limiter.Close(); err != nil {
+			log.Printf("Cannot save ingest usage. Error: %v", err)
+		}
+	}
 	a.queue.Close()
 	if err := a.processor.Shutdown(); err != nil {
 		return fmt.Errorf("processor shutdown failed: %w", err)
 	}
+	if err := a.tail.close(); err != nil {
+		log.Printf("Cannot close tail index. Error: %v", err)
+	}
+	if err := a.tenants.Close(); err != nil {
+		return fmt.Errorf("cannot close indexes: %w", err)
+	}
 
 	return nil
 }
 
+// endStreams ends live tails, which never end on their own, so that the
+// servers can shut down. It may be called more than once.
+func (a *App) endStreams() {
+	select {
+	case <-a.done:
+	default:
+		close(a.done)
+	}
+}
+
 func getEnvDefault(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -79,13 +247,35 @@ func getEnvDefault(key, defaultValue string) string {
 
 func Run(cmd *cobra.Command, args []string) {
 	port, _ := cmd.Flags().GetInt("port")
-	portString := fmt.Sprintf("%d",port)
+	portString := fmt.Sprintf("%d", port)
+	gelfUDP, _ := cmd.Flags().GetString("gelf-udp")
+	gelfTCP, _ := cmd.Flags().GetString("gelf-tcp")
+	configFile, _ := cmd.Flags().GetString("config")
+	authKeys, _ := cmd.Flags().GetString("auth-keys")
+	tlsCert, _ := cmd.Flags().GetString("tls-cert")
+	tlsKey, _ := cmd.Flags().GetString("tls-key")
+	tlsClientCA, _ := cmd.Flags().GetString("tls-client-ca")
+	requireClientCert, _ := cmd.Flags().GetBool("tls-require-client-cert")
+	grpcPort, _ := cmd.Flags().GetInt("grpc-port")
 
 	cfg := Config{
 		IndexName:     getEnvDefault("INDEX_PREFIX", "index-storage/index"),
 		RetentionDays: 12 * 24 * time.Hour,
 		ShutdownTimer: 5 * time.Second,
 		Port:          portString,
+		GelfUDPAddr:   gelfUDP,
+		GelfTCPAddr:   gelfTCP,
+		ConfigFile:    configFile,
+		AuthKeysFile:  authKeys,
+		TLS: tlsconfig.ServerOptions{
+			CertFile:     tlsCert,
+			KeyFile:      tlsKey,
+			ClientCAFile: tlsClientCA,
+		},
+		RequireClientCert: requireClientCert,
+	}
+	if grpcPort > 0 {
+		cfg.GRPCPort = fmt.Sprintf("%d", grpcPort)
 	}
 
 	server := NewServer(cfg)
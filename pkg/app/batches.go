@@ -0,0 +1,78 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+const (
+	// batchKeyTTL is how long the result of a keyed bulk request is kept.
+	batchKeyTTL = 10 * time.Minute
+	// maxRecentBatches caps the keys kept; the oldest go first.
+	maxRecentBatches = 10000
+)
+
+// recentBatches remembers the results of bulk requests sent with an
+// Idempotency-Key, so a client retrying a batch whose answer it lost does not
+// index it twice. Keys are scoped to the tenant that sent them. A retry after batchKeyTTL, after a restart or to another
+// server is ingested again.
+type recentBatches struct {
+	mu      sync.Mutex
+	results map[string]recentBatch
+}
+
+type recentBatch struct {
+	result types.BulkResult
+	at     time.Time
+}
+
+func newRecentBatches() *recentBatches {
+	return &recentBatches{results: map[string]recentBatch{}}
+}
+
+func batchKey(tenant, key string) string {
+	return tenant + "\x00" + key
+}
+
+// get returns the result of an earlier request of tenant with key.
+func (rb *recentBatches) get(tenant, key string) (types.BulkResult, bool) {
+	if key == "" {
+		return types.BulkResult{}, false
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	batch, ok := rb.results[batchKey(tenant, key)]
+	if !ok || time.Since(batch.at) > batchKeyTTL {
+		return types.BulkResult{}, false
+	}
+	return batch.result, true
+}
+
+// put records the result of the request of tenant with key.
+func (rb *recentBatches) put(tenant, key string, result types.BulkResult) {
+	if key == "" {
+		return
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	now := time.Now()
+	if len(rb.results) >= maxRecentBatches {
+		var oldestKey string
+		var oldest time.Time
+		for k, batch := range rb.results {
+			if now.Sub(batch.at) > batchKeyTTL {
+				delete(rb.results, k)
+			} else if oldestKey == "" || batch.at.Before(oldest) {
+				oldestKey, oldest = k, batch.at
+			}
+		}
+		if len(rb.results) >= maxRecentBatches {
+			delete(rb.results, oldestKey)
+		}
+	}
+	rb.results[batchKey(tenant, key)] = recentBatch{result: result, at: now}
+}
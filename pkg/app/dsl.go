@@ -0,0 +1,196 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// maxDSLDepth limits how deep bool clauses can be nested.
+const maxDSLDepth = 16
+
+// dslQuery maps a JSON DSL query onto bleve queries. Values are used as
+// given, so no character needs escaping.
+func dslQuery(q types.Query, depth int) (query.Query, error) {
+	if depth > maxDSLDepth {
+		return nil, fmt.Errorf("bool queries can be nested at most %d deep", maxDSLDepth)
+	}
+
+	set := 0
+	for _, clause := range []bool{
+		q.Bool != nil, q.Term != nil, q.Match != nil, q.Phrase != nil, q.Prefix != nil,
+		q.Wildcard != nil, q.Regexp != nil, q.Range != nil, q.Exists != nil,
+	} {
+		if clause {
+			set++
+		}
+	}
+	if set == 0 {
+		return nil, fmt.Errorf("empty query clause")
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("a query clause can hold only one query, use bool to combine them")
+	}
+
+	switch {
+	case q.Bool != nil:
+		return dslBool(*q.Bool, depth)
+	case q.Term != nil:
+		return dslField("term", q.Term, func(value string) query.FieldableQuery { return bleve.NewTermQuery(value) })
+	case q.Match != nil:
+		return dslField("match", q.Match, func(value string) query.FieldableQuery { return bleve.NewMatchQuery(value) })
+	case q.Phrase != nil:
+		return dslField("phrase", q.Phrase, func(value string) query.FieldableQuery { return bleve.NewMatchPhraseQuery(value) })
+	case q.Prefix != nil:
+		return dslField("prefix", q.Prefix, func(value string) query.FieldableQuery { return bleve.NewPrefixQuery(value) })
+	case q.Wildcard != nil:
+		return dslField("wildcard", q.Wildcard, func(value string) query.FieldableQuery { return bleve.NewWildcardQuery(value) })
+	case q.Regexp != nil:
+		if _, err := regexp.Compile(q.Regexp.Value); err != nil {
+			return nil, fmt.Errorf("regexp: %v", err)
+		}
+		return dslField("regexp", q.Regexp, func(value string) query.FieldableQuery { return bleve.NewRegexpQuery(value) })
+	case q.Range != nil:
+		return dslRange(*q.Range)
+	default:
+		return dslExists(*q.Exists)
+	}
+}
+
+func dslBool(b types.BoolQuery, depth int) (query.Query, error) {
+	convert := func(name string, clauses []types.Query) ([]query.Query, error) {
+		var queries []query.Query
+		for i, clause := range clauses {
+			q, err := dslQuery(clause, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("bool.%s[%d]: %w", name, i, err)
+			}
+			queries = append(queries, q)
+		}
+		return queries, nil
+	}
+
+	must, err := convert("must", b.Must)
+	if err != nil {
+		return nil, err
+	}
+	should, err := convert("should", b.Should)
+	if err != nil {
+		return nil, err
+	}
+	mustNot, err := convert("must_not", b.MustNot)
+	if err != nil {
+		return nil, err
+	}
+	if len(must)+len(should)+len(mustNot) == 0 {
+		return nil, fmt.Errorf("bool needs at least one clause")
+	}
+	if b.MinimumShouldMatch < 0 || b.MinimumShouldMatch > len(should) {
+		return nil, fmt.Errorf("bool.minimum_should_match must be between 0 and the number of should clauses")
+	}
+
+	q := query.NewBooleanQuery(must, should, mustNot)
+	if b.MinimumShouldMatch > 0 {
+		q.SetMinShould(float64(b.MinimumShouldMatch))
+	}
+	return q, nil
+}
+
+func dslField(name string, fq *types.FieldQuery, newQuery func(value string) query.FieldableQuery) (query.Query, error) {
+	if fq.Field == "" {
+		return nil, fmt.Errorf("%s: field is required", name)
+	}
+	if fq.Value == "" {
+		return nil, fmt.Errorf("%s: value is required", name)
+	}
+	q := newQuery(fq.Value)
+	q.SetField(types.IndexField(fq.Field))
+	return q, nil
+}
+
+// dslRange builds a numeric range for number bounds and a date range for
+// timestamp bounds.
+func dslRange(r types.RangeQuery) (query.Query, error) {
+	if r.Field == "" {
+		return nil, fmt.Errorf("range: field is required")
+	}
+	if r.GT != nil && r.GTE != nil || r.LT != nil && r.LTE != nil {
+		return nil, fmt.Errorf("range: use only one of gt and gte, and of lt and lte")
+	}
+
+	lower, lowerInclusive := r.GTE, true
+	if r.GT != nil {
+		lower, lowerInclusive = r.GT, false
+	}
+	upper, upperInclusive := r.LTE, true
+	if r.LT != nil {
+		upper, upperInclusive = r.LT, false
+	}
+	if lower == nil && upper == nil {
+		return nil, fmt.Errorf("range: at least one bound is required")
+	}
+
+	var numbers, dates int
+	for _, bound := range []interface{}{lower, upper} {
+		switch bound.(type) {
+		case float64:
+			numbers++
+		case string:
+			dates++
+		}
+	}
+
+	var q query.FieldableQuery
+	switch {
+	case numbers > 0 && dates == 0:
+		var from, to *float64
+		if lower != nil {
+			v := lower.(float64)
+			from = &v
+		}
+		if upper != nil {
+			v := upper.(float64)
+			to = &v
+		}
+		q = bleve.NewNumericRangeInclusiveQuery(from, to, &lowerInclusive, &upperInclusive)
+	case dates > 0 && numbers == 0:
+		var start, end time.Time
+		var err error
+		if lower != nil {
+			if start, err = time.Parse(time.RFC3339Nano, lower.(string)); err != nil {
+				return nil, fmt.Errorf("range: invalid timestamp %q", lower)
+			}
+		}
+		if upper != nil {
+			if end, err = time.Parse(time.RFC3339Nano, upper.(string)); err != nil {
+				return nil, fmt.Errorf("range: invalid timestamp %q", upper)
+			}
+		}
+		q = bleve.NewDateRangeInclusiveQuery(start, end, &lowerInclusive, &upperInclusive)
+	default:
+		return nil, fmt.Errorf("range: bounds must all be numbers or all RFC 3339 timestamps")
+	}
+	q.SetField(types.IndexField(r.Field))
+	return q, nil
+}
+
+// dslExists matches any indexed term of the field. Numbers and timestamps are
+// indexed as numeric terms, so an unbounded numeric range covers them.
+func dslExists(e types.ExistsQuery) (query.Query, error) {
+	if e.Field == "" {
+		return nil, fmt.Errorf("exists: field is required")
+	}
+	field := types.IndexField(e.Field)
+
+	anyTerm := bleve.NewWildcardQuery("*")
+	anyTerm.SetField(field)
+	negInf := math.Inf(-1)
+	anyNumber := bleve.NewNumericRangeQuery(&negInf, nil)
+	anyNumber.SetField(field)
+	return bleve.NewDisjunctionQuery(anyTerm, anyNumber), nil
+}
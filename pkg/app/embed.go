@@ -0,0 +1,37 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// Ingest runs logs through the pipeline, sampling and multiline merging of
+// source and queues it for indexing, like an HTTP ingest request would.
+func (a *App) Ingest(source string, logs types.LogFormat) error {
+	return a.enqueue(source, logs)
+}
+
+// FlushMultiline queues the events still held by the multiline aggregators
+// and returns the errors queueing them.
+func (a *App) FlushMultiline() error {
+	var errs []error
+	for _, aggregator := range a.multiline {
+		if err := aggregator.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Search searches the indexes of tenant.
+func (a *App) Search(ctx context.Context, tenant string, query types.SearchFormat) (*types.SearchResponse, error) {
+	return a.searchWithQuery(ctx, tenant, query)
+}
+
+// AfterIndex registers hook with the log processor. It must be called
+// before Start.
+func (a *App) AfterIndex(hook IndexHook) {
+	a.processor.AfterIndex(hook)
+}
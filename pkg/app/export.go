@@ -0,0 +1,248 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/julienschmidt/httprouter"
+)
+
+// exportPageSize is how many hits are read per search while exporting.
+const exportPageSize = 1000
+
+// defaultCSVColumns are exported to CSV when no columns are selected.
+var defaultCSVColumns = []string{"timestamp", "level", "message"}
+
+// export streams every match of a search in timestamp order, flushing
+// after each page, and stops when the client goes away or the export
+// timeout passes.
+func (app App) export(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tenant, err := requestTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), tenantStatus(err))
+		return
+	}
+	var exportFormat types.ExportFormat
+	if err := json.NewDecoder(r.Body).Decode(&exportFormat); err != nil {
+		http.Error(w, "Invalid export request", http.StatusBadRequest)
+		return
+	}
+	if len(exportFormat.Aggregations) > 0 || exportFormat.Highlight != nil {
+		http.Error(w, "Export does not support aggregations or highlight", http.StatusBadRequest)
+		return
+	}
+	q, err := searchQuery(exportFormat.SearchFormat)
+	if err != nil {
+		http.Error(w, err.Error(), searchStatus(err))
+		return
+	}
+
+	var out exportWriter
+	switch exportFormat.Format {
+	case "", types.ExportNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		out = &ndjsonExport{encoder: json.NewEncoder(w), columns: exportColumns(exportFormat.Columns)}
+	case types.ExportCSV:
+		columns := exportColumns(exportFormat.Columns)
+		if len(columns) == 0 {
+			columns = defaultCSVColumns
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="logs.csv"`)
+		out = &csvExport{writer: csv.NewWriter(w), columns: columns}
+	default:
+		http.Error(w, fmt.Sprintf("Unknown export format %q", exportFormat.Format), http.StatusBadRequest)
+		return
+	}
+
+	exportCtx, done, err := app.searches.beginExport(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), searchStatus(err))
+		return
+	}
+	defer done()
+	// a client that stops reading blocks writes, which the context cannot
+	// interrupt
+	if deadline, ok := exportCtx.Deadline(); ok {
+		http.NewResponseController(w).SetWriteDeadline(deadline)
+	}
+
+	ilm, ok := app.tenants.Lookup(tenant)
+	if !ok {
+		out.Flush()
+		return
+	}
+	ilm, _ = ilm.pruned(exportFormat.Start, exportFormat.End)
+	flusher, _ := w.(http.Flusher)
+
+	exported := 0
+	err = ilm.scan(exportCtx, q, exportPageSize, func(_ string, logs types.LogFormat) error {
+		if err := out.Write(logs); err != nil {
+			return err
+		}
+		exported++
+		if exported%exportPageSize == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		if r.Context().Err() != nil {
+			log.Printf("Export cancelled by the client after %v logs", exported)
+			return
+		}
+		err = app.searches.exportError(r.Context(), exportCtx, err)
+		log.Printf("Cannot export with Query: %v after %v logs, Error: %v", exportFormat.Query, exported, err)
+		if exported == 0 {
+			http.Error(w, "Failed to export: "+err.Error(), searchStatus(err))
+		}
+		// otherwise the response is cut short, which the client sees as a
+		// truncated body
+		return
+	}
+	log.Printf("Exported %v logs", exported)
+}
+
+// errStopScan ends a scan early without an error.
+var errStopScan = errors.New("stop scan")
+
+// scan calls fn with the ID and log of every match of q in timestamp order.
+func (ilm *IndexLifecycleManager) scan(ctx context.Context, q query.Query, pageSize int, fn func(id string, logs types.LogFormat) error) error {
+	return ilm.scanPages(ctx, q, pageSize, func(page types.SearchResponse) error {
+		for _, hit := range page.Hits {
+			if err := fn(hit.ID, hit.Log()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scanPages calls fn with every page of matches of q in timestamp order. It
+// pages through the indexes with search_after, reading pageSize hits per
+// search, so memory use does not grow with the result. fn may return
+// errStopScan to end the scan early.
+func (ilm *IndexLifecycleManager) scanPages(ctx context.Context, q query.Query, pageSize int, fn func(page types.SearchResponse) error) error {
+	var after []string
+	for {
+		searchRequest := bleve.NewSearchRequestOptions(q, pageSize, 0, false)
+		searchRequest.Fields = []string{"*"}
+		searchRequest.SortBy([]string{"timestamp", "_id"})
+		if after != nil {
+			searchRequest.SetSearchAfter(after)
+		}
+
+		result, err := ilm.indexSearch.SearchInContext(ctx, searchRequest)
+		if err != nil {
+			return err
+		}
+		page := newSearchResponse(result)
+		for _, hit := range page.Hits {
+			// the stored timestamp is cut to seconds, the indexed one is exact
+			if timestamp, err := sortTimestamp(hit.Sort[0]); err == nil && hit.Fields != nil {
+				hit.Fields["timestamp"] = timestamp.Format(time.RFC3339Nano)
+			}
+		}
+		if err := fn(page); err == errStopScan {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if len(result.Hits) < pageSize {
+			return nil
+		}
+		after = result.Hits[len(result.Hits)-1].Sort
+	}
+}
+
+// exportColumns accepts structured field names with or without the
+// "fields." prefix.
+func exportColumns(columns []string) []string {
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, strings.TrimPrefix(column, "fields."))
+	}
+	return names
+}
+
+type exportWriter interface {
+	Write(logs types.LogFormat) error
+	Flush() error
+}
+
+// ndjsonExport writes one JSON log per line, reduced to columns if any.
+type ndjsonExport struct {
+	encoder *json.Encoder
+	columns []string
+}
+
+func (e *ndjsonExport) Write(logs types.LogFormat) error {
+	if len(e.columns) == 0 {
+		return e.encoder.Encode(logs)
+	}
+	row := make(map[string]interface{}, len(e.columns))
+	for _, column := range e.columns {
+		if value, ok := logs.GetField(column); ok {
+			row[column] = value
+		}
+	}
+	return e.encoder.Encode(row)
+}
+
+func (e *ndjsonExport) Flush() error {
+	return nil
+}
+
+// csvExport writes a header row and one row per log. Missing fields are
+// left empty.
+type csvExport struct {
+	writer      *csv.Writer
+	columns     []string
+	wroteHeader bool
+}
+
+func (e *csvExport) Write(logs types.LogFormat) error {
+	if err := e.header(); err != nil {
+		return err
+	}
+	row := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		row[i], _ = logs.GetString(column)
+	}
+	return e.writer.Write(row)
+}
+
+func (e *csvExport) Flush() error {
+	if err := e.header(); err != nil {
+		return err
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvExport) header() error {
+	if e.wroteHeader {
+		return nil
+	}
+	e.wroteHeader = true
+	return e.writer.Write(e.columns)
+}
@@ -0,0 +1,436 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/adiyakaihsan/go-logger/pkg/auth"
+	"github.com/adiyakaihsan/go-logger/pkg/loggerpb"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const grpcDefaultSource = "grpc"
+
+// grpcRoles are the roles required by each method.
+var grpcRoles = map[string]auth.Role{
+	loggerpb.LogService_Ingest_FullMethodName:       auth.RoleIngest,
+	loggerpb.LogService_IngestStream_FullMethodName: auth.RoleIngest,
+	loggerpb.LogService_Search_FullMethodName:       auth.RoleRead,
+	loggerpb.LogService_Tail_FullMethodName:         auth.RoleRead,
+}
+
+// grpcServer implements the LogService of api/logger.proto.
+type grpcServer struct {
+	loggerpb.UnimplementedLogServiceServer
+
+	app               *App
+	auth              *auth.Store
+	requireClientCert bool
+}
+
+// newGRPCServer serves the log service of app. keys may be nil to leave the
+// API open, like the HTTP API.
+func newGRPCServer(app *App, keys *auth.Store, tlsConfig *tls.Config, requireClientCert bool) *grpc.Server {
+	s := &grpcServer{app: app, auth: keys, requireClientCert: requireClientCert}
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	loggerpb.RegisterLogServiceServer(server, s)
+	return server
+}
+
+// authorize checks the API key in the call metadata against the role of
+// method and returns ctx carrying the caller's identity.
+func (s *grpcServer) authorize(ctx context.Context, method string) (context.Context, error) {
+	role := grpcRoles[method]
+	if role == auth.RoleIngest && s.requireClientCert && !hasVerifiedClientCert(ctx) {
+		return nil, status.Error(codes.Unauthenticated, "client certificate required")
+	}
+	if s.auth == nil {
+		return ctx, nil
+	}
+
+	token := metadataValue(ctx, "x-api-key")
+	if header := metadataValue(ctx, "authorization"); token == "" && len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		token = strings.TrimSpace(header[7:])
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing API key")
+	}
+	id, ok := s.auth.Authenticate(token)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	if !id.Allows(role) {
+		return nil, status.Errorf(codes.PermissionDenied, "API key is not allowed to %s", role)
+	}
+	return auth.WithIdentity(ctx, id), nil
+}
+
+func (s *grpcServer) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *grpcServer) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authorize(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &identityStream{ServerStream: stream, ctx: ctx})
+}
+
+// identityStream overrides the context of a stream with the authorized one.
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+func hasVerifiedClientCert(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(info.State.VerifiedChains) > 0
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// callInfo returns the source and tenant of a call.
+func callInfo(ctx context.Context) (string, string, error) {
+	source := metadataValue(ctx, "x-source")
+	if source == "" {
+		source = grpcDefaultSource
+	}
+	tenant, err := resolveTenant(ctx, metadataValue(ctx, strings.ToLower(tenantHeader)))
+	if err != nil {
+		if errors.Is(err, errTenantForbidden) {
+			return "", "", status.Error(codes.PermissionDenied, err.Error())
+		}
+		return "", "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	return source, tenant, nil
+}
+
+// allow applies the ingest rate limits to records logs of size bytes.
+func (s *grpcServer) allow(ctx context.Context, source, tenant string, records, bytes int64) error {
+	if s.app.limiter == nil {
+		return nil
+	}
+	var keyName string
+	if id, ok := auth.FromContext(ctx); ok {
+		keyName = id.Name
+	}
+	d := s.app.limiter.Allow(s.app.limitKey(keyName, source, tenant), records, bytes)
+	if d.Allowed {
+		return nil
+	}
+	retryAfter := strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds())))
+	grpc.SetHeader(ctx, metadata.Pairs("retry-after", retryAfter))
+	return status.Errorf(codes.ResourceExhausted, "too many requests: %s", d.Reason)
+}
+
+// ingestOne limits and queues a single log.
+func (s *grpcServer) ingestOne(ctx context.Context, source, tenant string, in *loggerpb.LogRecord) error {
+	if err := s.allow(ctx, source, tenant, 1, int64(proto.Size(in))); err != nil {
+		return err
+	}
+
+	logs, err := fromRecord(in)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid log: %v", err)
+	}
+	logs.Tenant = tenant
+	if err := s.app.enqueue(source, logs); err != nil {
+		log.Printf("Cannot enqueue logs. Error: %v", err)
+		return status.Error(codes.Unavailable, "cannot queue log")
+	}
+	return nil
+}
+
+func (s *grpcServer) Ingest(ctx context.Context, in *loggerpb.LogRecord) (*loggerpb.IngestResult, error) {
+	source, tenant, err := callInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ingestOne(ctx, source, tenant, in); err != nil {
+		return nil, err
+	}
+	return &loggerpb.IngestResult{Accepted: 1}, nil
+}
+
+// IngestStream queues every log sent on the stream and answers with the
+// totals once the client closes it. Invalid logs are counted as failed.
+func (s *grpcServer) IngestStream(stream loggerpb.LogService_IngestStreamServer) error {
+	ctx := stream.Context()
+	source, tenant, err := callInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := &loggerpb.IngestResult{}
+	for {
+		in, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.ingestOne(ctx, source, tenant, in); err != nil {
+			if status.Code(err) != codes.InvalidArgument {
+				return err
+			}
+			result.Failed++
+			continue
+		}
+		result.Accepted++
+	}
+	return stream.SendAndClose(result)
+}
+
+// Search streams the hits of the query in timestamp order, one page per
+// response, sending each page as soon as it is read. It skips from hits and
+// stops after size hits when set, else it streams every match. Streams
+// share the export slots and timeout.
+func (s *grpcServer) Search(in *loggerpb.SearchRequest, stream loggerpb.LogService_SearchServer) error {
+	ctx := stream.Context()
+	_, tenant, err := callInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := types.SearchFormat{Query: in.GetQuery()}
+	if in.Start != nil {
+		if err := in.Start.CheckValid(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid start: %v", err)
+		}
+		query.Start = in.Start.AsTime()
+	}
+	if in.End != nil {
+		if err := in.End.CheckValid(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid end: %v", err)
+		}
+		query.End = in.End.AsTime()
+	}
+	if in.GetFrom() < 0 || in.GetSize() < 0 {
+		return status.Error(codes.InvalidArgument, "from and size cannot be negative")
+	}
+	q, err := searchQuery(query)
+	if err != nil {
+		return searchGRPCError(err)
+	}
+
+	// like exports, streams take an export slot and the export timeout
+	exportCtx, done, err := s.app.searches.beginExport(ctx)
+	if err != nil {
+		return searchGRPCError(err)
+	}
+	defer done()
+
+	ilm, ok := s.app.tenants.Lookup(tenant)
+	if !ok {
+		return stream.Send(&loggerpb.SearchResponse{})
+	}
+	ilm, _ = ilm.pruned(query.Start, query.End)
+
+	skip, size := int(in.GetFrom()), int(in.GetSize())
+	var total uint64
+	sent := 0
+	err = ilm.scanPages(exportCtx, q, exportPageSize, func(page types.SearchResponse) error {
+		total = page.TotalHits
+		out := &loggerpb.SearchResponse{TotalHits: page.TotalHits}
+		for _, hit := range page.Hits {
+			if skip > 0 {
+				skip--
+				continue
+			}
+			pbHit, err := toHit(hit)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			out.Hits = append(out.Hits, pbHit)
+			if sent+len(out.Hits) == size {
+				break
+			}
+		}
+		if len(out.Hits) == 0 {
+			return nil
+		}
+		if err := stream.Send(out); err != nil {
+			return err
+		}
+		sent += len(out.Hits)
+		if sent == size {
+			return errStopScan
+		}
+		return nil
+	})
+	if err == nil && sent == 0 {
+		// every response carries the total, even without hits
+		err = stream.Send(&loggerpb.SearchResponse{TotalHits: total})
+	}
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return status.FromContextError(ctx.Err()).Err()
+	default:
+		if _, ok := status.FromError(err); ok && exportCtx.Err() == nil {
+			return err
+		}
+		return searchGRPCError(s.app.searches.exportError(ctx, exportCtx, err))
+	}
+}
+
+// searchGRPCError maps search errors to status codes like searchStatus.
+func searchGRPCError(err error) error {
+	switch {
+	case errors.Is(err, errInvalidSearch):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, errTooManySearches):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, errSearchTimeout):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// Tail streams newly indexed logs matching the query until the client
+// cancels.
+func (s *grpcServer) Tail(in *loggerpb.TailRequest, stream loggerpb.LogService_TailServer) error {
+	ctx := stream.Context()
+	_, tenant, err := callInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	q, err := parseTailQuery(in.GetQuery())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	sub := s.app.tail.subscribe(tenant, q)
+	defer s.app.tail.unsubscribe(sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.app.done:
+			return status.Error(codes.Unavailable, "server shutting down")
+		case indexed := <-sub.logs:
+			out, err := toRecord(indexed.logs)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			if err := stream.Send(out); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// fromRecord converts a LogRecord to the log the HTTP API would ingest.
+func fromRecord(in *loggerpb.LogRecord) (types.LogFormat, error) {
+	logs := types.LogFormat{
+		Level:      in.GetLevel(),
+		Message:    in.GetMessage(),
+		SampleRate: in.GetSampleRate(),
+	}
+	if in.Timestamp != nil {
+		if err := in.Timestamp.CheckValid(); err != nil {
+			return logs, err
+		}
+		logs.Timestamp = in.Timestamp.AsTime()
+	}
+	if in.Fields != nil {
+		logs.Fields = in.Fields.AsMap()
+	}
+	return logs, nil
+}
+
+func toRecord(logs types.LogFormat) (*loggerpb.LogRecord, error) {
+	out := &loggerpb.LogRecord{
+		Level:      logs.Level,
+		Message:    logs.Message,
+		SampleRate: logs.SampleRate,
+	}
+	if !logs.Timestamp.IsZero() {
+		out.Timestamp = timestamppb.New(logs.Timestamp)
+	}
+	if len(logs.Fields) > 0 {
+		fields, err := toStruct(logs.Fields)
+		if err != nil {
+			return nil, err
+		}
+		out.Fields = fields
+	}
+	return out, nil
+}
+
+func toHit(hit types.SearchHit) (*loggerpb.SearchHit, error) {
+	record, err := toRecord(hit.Log())
+	if err != nil {
+		return nil, err
+	}
+	return &loggerpb.SearchHit{Id: hit.ID, Index: hit.Index, Score: hit.Score, Log: record}, nil
+}
+
+// toStruct converts structured fields, falling back to a JSON round trip for
+// values structpb does not know, such as []string set by pipelines.
+func toStruct(fields map[string]interface{}) (*structpb.Struct, error) {
+	if out, err := structpb.NewStruct(fields); err == nil {
+		return out, nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var generic map[string]interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(generic)
+}
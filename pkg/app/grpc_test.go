@@ -0,0 +1,301 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/loggerpb"
+	"github.com/adiyakaihsan/go-logger/pkg/queue"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newTestApp starts an app on an in-memory queue. indexed receives every log
+// once it is indexed.
+func newTestApp(t *testing.T) (*App, <-chan types.LogFormat) {
+	t.Helper()
+
+	a, err := NewAppWithQueue(Config{
+		IndexName:     filepath.Join(t.TempDir(), "index"),
+		RetentionDays: 24 * time.Hour,
+	}, &config.File{}, queue.NewChannelQueue())
+	if err != nil {
+		t.Fatalf("NewAppWithQueue: %v", err)
+	}
+	indexed := make(chan types.LogFormat, 16)
+	a.AfterIndex(func(_ string, logs types.LogFormat, err error) {
+		if err == nil {
+			indexed <- logs
+		}
+	})
+	if err := a.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { a.Shutdown() })
+	return a, indexed
+}
+
+// newTestGRPC starts a test app and serves its gRPC API over bufconn.
+func newTestGRPC(t *testing.T) (*App, loggerpb.LogServiceClient, <-chan types.LogFormat) {
+	t.Helper()
+
+	a, indexed := newTestApp(t)
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer(a, nil, nil, false)
+	go server.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	return a, loggerpb.NewLogServiceClient(conn), indexed
+}
+
+func waitIndexed(t *testing.T, indexed <-chan types.LogFormat, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-indexed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d logs indexed", i, n)
+		}
+	}
+}
+
+func testRecord(t *testing.T, level, message string, fields map[string]interface{}) *loggerpb.LogRecord {
+	t.Helper()
+	record := &loggerpb.LogRecord{
+		Timestamp: timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		Level:     level,
+		Message:   message,
+	}
+	if fields != nil {
+		s, err := structpb.NewStruct(fields)
+		if err != nil {
+			t.Fatalf("NewStruct: %v", err)
+		}
+		record.Fields = s
+	}
+	return record
+}
+
+func TestGRPCIngestAndSearch(t *testing.T) {
+	_, client, indexed := newTestGRPC(t)
+	ctx := context.Background()
+
+	result, err := client.Ingest(ctx, testRecord(t, "error", "payment failed", map[string]interface{}{"service": "billing"}))
+	if err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if result.Accepted != 1 {
+		t.Errorf("Ingest result = %v, want 1 accepted", result)
+	}
+
+	stream, err := client.IngestStream(ctx)
+	if err != nil {
+		t.Fatalf("IngestStream: %v", err)
+	}
+	for _, record := range []*loggerpb.LogRecord{
+		testRecord(t, "info", "payment retried", nil),
+		{Message: "bad timestamp", Timestamp: &timestamppb.Timestamp{Nanos: -1}},
+		testRecord(t, "info", "payment done", nil),
+	} {
+		if err := stream.Send(record); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	result, err = stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv: %v", err)
+	}
+	if result.Accepted != 2 || result.Failed != 1 {
+		t.Errorf("IngestStream result = %v, want 2 accepted and 1 failed", result)
+	}
+	waitIndexed(t, indexed, 3)
+
+	search, err := client.Search(ctx, &loggerpb.SearchRequest{Query: "level:error"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var hits []*loggerpb.SearchHit
+	for {
+		page, err := search.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if page.TotalHits != 1 {
+			t.Errorf("total hits = %d, want 1", page.TotalHits)
+		}
+		hits = append(hits, page.Hits...)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("got %d hits, want 1", len(hits))
+	}
+	got := hits[0].Log
+	if got.Message != "payment failed" || got.Level != "error" || got.Fields.AsMap()["service"] != "billing" {
+		t.Errorf("hit log = %v", got)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !got.Timestamp.AsTime().Equal(want) {
+		t.Errorf("hit timestamp = %v, want %v", got.Timestamp.AsTime(), want)
+	}
+}
+
+func TestGRPCTail(t *testing.T) {
+	a, client, indexed := newTestGRPC(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tail, err := client.Tail(ctx, &loggerpb.TailRequest{Query: "level:error"})
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	// the server subscribes once the call reaches it
+	waitSubscribed(t, a)
+
+	if _, err := client.Ingest(ctx, testRecord(t, "info", "filtered out", nil)); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if _, err := client.Ingest(ctx, testRecord(t, "error", "disk full", nil)); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	waitIndexed(t, indexed, 2)
+	got, err := tail.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if got.Message != "disk full" || got.Level != "error" {
+		t.Errorf("tailed log = %v", got)
+	}
+}
+
+func TestGRPCTailEnds(t *testing.T) {
+	a, client, _ := newTestGRPC(t)
+	ctx := context.Background()
+
+	tail, err := client.Tail(ctx, &loggerpb.TailRequest{Query: "level:>"})
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	if _, err := tail.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Recv with an invalid query = %v, want %v", err, codes.InvalidArgument)
+	}
+
+	tail, err = client.Tail(ctx, &loggerpb.TailRequest{})
+	if err != nil {
+		t.Fatalf("Tail: %v", err)
+	}
+	waitSubscribed(t, a)
+	a.endStreams()
+	if _, err := tail.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("Recv after shutdown = %v, want %v", err, codes.Unavailable)
+	}
+}
+
+func TestGRPCSearchRange(t *testing.T) {
+	_, client, indexed := newTestGRPC(t)
+	ctx := context.Background()
+
+	// testRecord logs are stamped 2024-01-02T03:04:05Z
+	if _, err := client.Ingest(ctx, testRecord(t, "error", "payment failed", nil)); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	waitIndexed(t, indexed, 1)
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       uint64
+	}{
+		{"unbounded", time.Time{}, time.Time{}, 1},
+		{"start before", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Time{}, 1},
+		{"start after", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), time.Time{}, 0},
+		{"end before", time.Time{}, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := &loggerpb.SearchRequest{Query: "level:error"}
+			if !test.start.IsZero() {
+				request.Start = timestamppb.New(test.start)
+			}
+			if !test.end.IsZero() {
+				request.End = timestamppb.New(test.end)
+			}
+			search, err := client.Search(ctx, request)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			page, err := search.Recv()
+			if err != nil {
+				t.Fatalf("Recv: %v", err)
+			}
+			if page.TotalHits != test.want {
+				t.Errorf("total hits = %d, want %d", page.TotalHits, test.want)
+			}
+		})
+	}
+}
+
+func TestGRPCSearchWindow(t *testing.T) {
+	_, client, indexed := newTestGRPC(t)
+	ctx := context.Background()
+
+	for _, message := range []string{"first", "second", "third"} {
+		if _, err := client.Ingest(ctx, testRecord(t, "error", message, nil)); err != nil {
+			t.Fatalf("Ingest: %v", err)
+		}
+	}
+	waitIndexed(t, indexed, 3)
+
+	search, err := client.Search(ctx, &loggerpb.SearchRequest{Query: "level:error", From: 1, Size: 1})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var hits []*loggerpb.SearchHit
+	for {
+		page, err := search.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if page.TotalHits != 3 {
+			t.Errorf("total hits = %d, want 3", page.TotalHits)
+		}
+		hits = append(hits, page.Hits...)
+	}
+	if len(hits) != 1 {
+		t.Errorf("got %d hits, want 1", len(hits))
+	}
+
+	search, err = client.Search(ctx, &loggerpb.SearchRequest{Query: "level:error", Size: -1})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if _, err := search.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Recv with a negative size = %v, want %v", err, codes.InvalidArgument)
+	}
+}
@@ -1,36 +1,168 @@
 package app
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 
+	"github.com/adiyakaihsan/go-logger/pkg/parser"
 	"github.com/adiyakaihsan/go-logger/pkg/types"
 	"github.com/julienschmidt/httprouter"
 )
 
+// maxIngestBody bounds the size of a single ingest request.
+const maxIngestBody = 32 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// readBody reads an ingest request body, decompressing it when it is sent
+// with Content-Encoding: gzip. The size limit applies after decompression.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body := io.Reader(http.MaxBytesReader(w, r.Body, maxIngestBody))
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxIngestBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxIngestBody {
+		return nil, errBodyTooLarge
+	}
+	return data, nil
+}
+
 func (app App) ingester(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	source := requestSource(r)
+	tenant, err := requestTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), tenantStatus(err))
+		return
+	}
+	body, err := readBody(w, r)
+	if err != nil {
+		http.Error(w, "Cannot read request body", http.StatusBadRequest)
+		return
+	}
+
+	format := app.requestFormat(r, source)
+	if !app.allowIngest(w, r, source, tenant, ingestRecords(format, body), body) {
+		return
+	}
+
+	// raw lines in another format are handled like a bulk request
+	if format != "json" {
+		p, err := parser.Get(format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := app.ingestLines(bytes.NewReader(body), source, tenant, p); err != nil {
+			ingestError(w, err)
+			return
+		}
+		w.Write([]byte("OK"))
+		return
+	}
+
 	var logs types.LogFormat
 
-	if err := json.NewDecoder(r.Body).Decode(&logs); err != nil {
+	if err := json.Unmarshal(body, &logs); err != nil {
 		log.Printf("Cannot decode log. Error: %v", err)
 	}
-	if err := app.queue.Enqueue(logs); err != nil {
+	logs.Tenant = tenant
+	if err := app.enqueue(source, logs); err != nil {
 		log.Printf("Cannot enqueue logs. Error: %v", err)
+		ingestError(w, fmt.Errorf("%w: %v", errQueueUnavailable, err))
+		return
 	}
 
 	w.Write([]byte("OK"))
 }
 
+// ingestError answers 503 when logs could not be queued, so that clients
+// retry them, and 400 when the body could not be read.
+func ingestError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errQueueUnavailable) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, "Cannot read request body", http.StatusBadRequest)
+}
+
+// bulkIngester accepts one log per line, as JSON unless ?format= says
+// otherwise. A batch sent again under the same Idempotency-Key after it was
+// fully accepted is answered without ingesting it twice.
+func (app App) bulkIngester(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	source := requestSource(r)
+	tenant, err := requestTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), tenantStatus(err))
+		return
+	}
+
+	key := r.Header.Get(types.IdempotencyKeyHeader)
+	if result, ok := app.batches.get(tenant, key); ok {
+		log.Printf("Skipping bulk request %v, it was already ingested", key)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	p, err := parser.Get(app.requestFormat(r, source))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, err := readBody(w, r)
+	if err != nil {
+		http.Error(w, "Cannot read request body", http.StatusBadRequest)
+		return
+	}
+	if !app.allowIngest(w, r, source, tenant, int64(countLines(body)), body) {
+		return
+	}
+
+	result, err := app.ingestLines(bytes.NewReader(body), source, tenant, p)
+	if err != nil {
+		ingestError(w, err)
+		return
+	}
+	if result.Failed == 0 {
+		app.batches.put(tenant, key, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 func (app App) search(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	var searchQuery types.SearchFormat
 
+	tenant, err := requestTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), tenantStatus(err))
+		return
+	}
 	if err := json.NewDecoder(r.Body).Decode(&searchQuery); err != nil {
 		log.Printf("Cannot decode log. Error: %v", err)
 	}
-	searchResults, err := app.searchWithQuery(searchQuery)
+	searchResults, err := app.searchWithQuery(r.Context(), tenant, searchQuery)
 	if err != nil {
 		log.Printf("Cannot search with Query: %v, Error: %v", searchQuery.Query, err)
+		http.Error(w, err.Error(), searchStatus(err))
 		return
 	}
 
@@ -44,3 +176,44 @@ func (app App) search(w http.ResponseWriter, r *http.Request, _ httprouter.Param
 
 	w.Write(resultJSON)
 }
+
+// searchStatus is the HTTP status for an error from searchWithQuery.
+func searchStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidSearch):
+		return http.StatusBadRequest
+	case errors.Is(err, errTooManySearches):
+		return http.StatusTooManyRequests
+	case errors.Is(err, errSearchTimeout):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// redactionStats reports how many values each redaction rule has masked.
+func (app App) redactionStats(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	counts := map[string]int64{}
+	if app.redactor != nil {
+		counts = app.redactor.Counts()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counts)
+}
+
+// multilineStats reports, per source, how often queueing a merged multiline
+// event failed, how many events wait for a retry and how many were dropped.
+func (app App) multilineStats(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	failed := map[string]int64{}
+	retrying := map[string]int{}
+	dropped := map[string]int64{}
+	for source, aggregator := range app.multiline {
+		failed[source] = aggregator.Failed()
+		retrying[source] = aggregator.Retrying()
+		dropped[source] = aggregator.Dropped()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"failed": failed, "retrying": retrying, "dropped": dropped})
+}
@@ -1,18 +1,23 @@
 package app
 
 import (
-	"errors"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/adiyakaihsan/go-logger/pkg/config"
 	"github.com/adiyakaihsan/go-logger/pkg/types"
 	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/mapping"
 	gocron "github.com/go-co-op/gocron/v2"
 )
 
@@ -23,26 +28,40 @@ type IndexLifecycleManager struct {
 	baseIndexName string
 	searchManager *SearchManager
 	retentionDays time.Duration
+	indexMapping  mapping.IndexMapping
 }
 
 type SearchManager struct {
-	alias   bleve.IndexAlias
+	alias bleve.IndexAlias
+	// mu guards indices and bounds.
+	mu      sync.RWMutex
 	indices map[string]bleve.Index
+	// bounds holds the oldest and newest timestamp in each index, zero for
+	// an empty one, so a search for a time range can skip the indexes
+	// outside it. Indexes whose bounds could not be read have no entry and
+	// are always searched.
+	bounds map[string]indexBounds
 }
 
-func NewIndexLifecycleManager(baseIndexName string, retentionDays time.Duration) (*IndexLifecycleManager, error) {
+type indexBounds struct {
+	oldest, newest time.Time
+}
+
+func NewIndexLifecycleManager(baseIndexName string, retentionDays time.Duration, indexMapping mapping.IndexMapping) (*IndexLifecycleManager, error) {
 	//Index Alias used by search
 	indexAlias := bleve.NewIndexAlias()
 
 	sm := &SearchManager{
 		alias:   indexAlias,
 		indices: map[string]bleve.Index{},
+		bounds:  map[string]indexBounds{},
 	}
 	ilm := &IndexLifecycleManager{
 		indexSearch:   indexAlias,
 		baseIndexName: baseIndexName,
 		searchManager: sm,
 		retentionDays: retentionDays,
+		indexMapping:  indexMapping,
 	}
 
 	index, err := ilm.getActiveIndex()
@@ -52,7 +71,7 @@ func NewIndexLifecycleManager(baseIndexName string, retentionDays time.Duration)
 
 	ilm.index = index
 
-	ilm.indexSearch.Add(index)
+	sm.add(index)
 	log.Printf("Added index: %v to Index Alias", index.Name())
 
 	// go startHourlyIndexRollover(&app, "index")
@@ -70,29 +89,86 @@ func NewIndexLifecycleManager(baseIndexName string, retentionDays time.Duration)
 
 }
 
-func (ilm *IndexLifecycleManager) indexWithRetry(logs types.LogFormat) {
+var (
+	// idNonce tells apart the document IDs of different runs, idCounter
+	// those of one run.
+	idNonce   = newIDNonce()
+	idCounter atomic.Uint64
+)
+
+func newIDNonce() string {
+	nonce := make([]byte, 4)
+	if _, err := rand.Read(nonce); err != nil {
+		binary.BigEndian.PutUint32(nonce, uint32(time.Now().UnixNano()))
+	}
+	return hex.EncodeToString(nonce)
+}
+
+// documentID returns a new ID to index a log under. It starts with the
+// timestamp, but many logs share a millisecond, so a unique suffix follows.
+func documentID(logs types.LogFormat) string {
+	return fmt.Sprintf("%s-%s%08x", logs.Timestamp.Format("20060102150405.000"), idNonce, idCounter.Add(1))
+}
+
+// indexWithRetry returns the document ID of the log, or the last error when
+// every attempt failed.
+func (ilm *IndexLifecycleManager) indexWithRetry(logs types.LogFormat) (string, error) {
 	var maxRetries = 3
 	var retryInterval = 5 * time.Second
+	var err error
 
 	log.Println("Indexing")
-	id := logs.Timestamp.Format("20060102150405.000")
+	id := documentID(logs)
+	index := ilm.index
+	// widen the bounds first so a search never skips an indexed log
+	ilm.searchManager.observe(index.Name(), logs.Timestamp)
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err := ilm.index.Index(id, logs)
+		err = index.Index(id, logs)
 		if err == nil {
 			log.Printf("Index ID: %v", id)
-			return
+			return id, nil
 		}
 		log.Printf("Cannot index data. Error: %v", err)
 		time.Sleep(retryInterval)
 
 	}
+	return "", err
 }
 
 func (ilm *IndexLifecycleManager) getHourlyIndexName() string {
-	currentHour := time.Now().Format("2006-01-02-15") // Year-Month-Day-Hour format
+	currentHour := time.Now().Format(hourlyIndexLayout)
 	return fmt.Sprintf("%s-%s.log", ilm.baseIndexName, currentHour)
 }
 
+// newIndexMapping indexes the given structured fields as whole keywords, so
+// they can be matched exactly and aggregated by value. Every other field is
+// mapped dynamically. Numbers in a keyword field stay numeric.
+func newIndexMapping(cfg config.IndexConfig) mapping.IndexMapping {
+	indexMapping := bleve.NewIndexMapping()
+	if len(cfg.KeywordFields) == 0 {
+		return indexMapping
+	}
+	fields := bleve.NewDocumentMapping()
+	for _, name := range cfg.KeywordFields {
+		// nested names such as "http.host" need a document mapping per level
+		path := strings.Split(strings.TrimPrefix(name, "fields."), ".")
+		doc := fields
+		for _, part := range path[:len(path)-1] {
+			sub, ok := doc.Properties[part]
+			if !ok {
+				sub = bleve.NewDocumentMapping()
+				doc.AddSubDocumentMapping(part, sub)
+			}
+			doc = sub
+		}
+		text := bleve.NewTextFieldMapping()
+		text.Analyzer = keyword.Name
+		doc.AddFieldMappingsAt(path[len(path)-1], text, bleve.NewNumericFieldMapping())
+	}
+	indexMapping.DefaultMapping.AddSubDocumentMapping("fields", fields)
+	return indexMapping
+}
+
 func (ilm *IndexLifecycleManager) getActiveIndex() (bleve.Index, error) {
 	var index bleve.Index
 
@@ -102,8 +178,7 @@ func (ilm *IndexLifecycleManager) getActiveIndex() (bleve.Index, error) {
 	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
 		// Index doesn't exist, so create a new one
 		log.Println("Index does not exist, creating new index...")
-		mapping := bleve.NewIndexMapping()
-		index, err = bleve.New(indexPath, mapping)
+		index, err = bleve.New(indexPath, ilm.indexMapping)
 		if err != nil {
 			log.Printf("Cannot create new index: %v", err)
 			return nil, err
@@ -156,6 +231,145 @@ func (ilm *IndexLifecycleManager) StopScheduler() {
 	ilm.scheduler.Shutdown()
 }
 
+// Close closes the active index and every index opened for search.
+func (ilm *IndexLifecycleManager) Close() error {
+	var firstErr error
+	closed := map[bleve.Index]bool{}
+	for _, index := range append([]bleve.Index{ilm.index}, ilm.searchManager.list()...) {
+		if index == nil || closed[index] {
+			continue
+		}
+		closed[index] = true
+		if err := index.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// add makes index searchable, reading its time bounds.
+func (sm *SearchManager) add(index bleve.Index) {
+	bounds, err := readBounds(index)
+	if err != nil {
+		log.Printf("Cannot read time bounds of %v. Error: %v", index.Name(), err)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.indices[index.Name()] = index
+	if err == nil {
+		sm.bounds[index.Name()] = bounds
+	}
+	sm.alias.Add(index)
+}
+
+func (sm *SearchManager) remove(index bleve.Index) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.indices, index.Name())
+	delete(sm.bounds, index.Name())
+	sm.alias.Remove(index)
+}
+
+func (sm *SearchManager) list() []bleve.Index {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	var list []bleve.Index
+	for _, index := range sm.indices {
+		list = append(list, index)
+	}
+	return list
+}
+
+// observe widens the bounds of the named index to include timestamp.
+// Unknown bounds stay unknown, as the logs already in the index are not.
+func (sm *SearchManager) observe(name string, timestamp time.Time) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	bounds, ok := sm.bounds[name]
+	if !ok {
+		return
+	}
+	if bounds.oldest.IsZero() || timestamp.Before(bounds.oldest) {
+		bounds.oldest = timestamp
+	}
+	if bounds.newest.IsZero() || timestamp.After(bounds.newest) {
+		bounds.newest = timestamp
+	}
+	sm.bounds[name] = bounds
+}
+
+// overlapping returns the indexes holding logs in [start, end), where a
+// zero time leaves that end open, and how many indexes there are in all.
+// Empty indexes never overlap; indexes with unknown bounds always do.
+func (sm *SearchManager) overlapping(start, end time.Time) ([]bleve.Index, int) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	var indexes []bleve.Index
+	for name, index := range sm.indices {
+		bounds, ok := sm.bounds[name]
+		if !ok {
+			indexes = append(indexes, index)
+			continue
+		}
+		if bounds.oldest.IsZero() {
+			continue
+		}
+		if !start.IsZero() && bounds.newest.Before(start) {
+			continue
+		}
+		if !end.IsZero() && !bounds.oldest.Before(end) {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, len(sm.indices)
+}
+
+// readBounds finds the oldest and newest timestamp in index, or zero times
+// when it is empty.
+func readBounds(index bleve.Index) (indexBounds, error) {
+	var bounds indexBounds
+	for _, order := range []string{"timestamp", "-timestamp"} {
+		searchRequest := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), 1, 0, false)
+		searchRequest.SortBy([]string{order})
+		result, err := index.Search(searchRequest)
+		if err != nil || len(result.Hits) == 0 {
+			return indexBounds{}, err
+		}
+		timestamp, err := sortTimestamp(result.Hits[0].Sort[0])
+		if err != nil {
+			return indexBounds{}, err
+		}
+		if order == "timestamp" {
+			bounds.oldest = timestamp
+		} else {
+			bounds.newest = timestamp
+		}
+	}
+	return bounds, nil
+}
+
+// pruned returns a view of ilm that searches only the indexes overlapping
+// [start, end), and how many indexes it skips. Without a time range it is
+// ilm itself.
+func (ilm *IndexLifecycleManager) pruned(start, end time.Time) (*IndexLifecycleManager, int) {
+	if start.IsZero() && end.IsZero() {
+		return ilm, 0
+	}
+	indexes, total := ilm.searchManager.overlapping(start, end)
+	if len(indexes) == total {
+		return ilm, 0
+	}
+	// an alias needs an index to search, the time range then finds nothing
+	if len(indexes) == 0 {
+		indexes = []bleve.Index{ilm.index}
+	}
+	view := *ilm
+	view.indexSearch = bleve.NewIndexAlias(indexes...)
+	return &view, total - len(indexes)
+}
+
 func (ilm *IndexLifecycleManager) indexRollover(baseIndexName string) {
 	newIndex, err := ilm.getActiveIndex()
 	if err != nil {
@@ -163,49 +377,41 @@ func (ilm *IndexLifecycleManager) indexRollover(baseIndexName string) {
 	}
 	ilm.index = newIndex
 	//update indexAlias for search
-	ilm.indexSearch.Add(newIndex)
-	ilm.searchManager.indices[newIndex.Name()] = newIndex
+	ilm.searchManager.add(newIndex)
 
 	log.Printf("Rolled over to new index: %s", newIndex.Name())
 
 }
 
-func isOlderThan(filename string, age time.Duration) (bool, error) {
-	// Regular expression to extract date components
-	re := regexp.MustCompile(`index-(\d{4})-(\d{2})-(\d{2})-\d{2}`)
-	matches := re.FindStringSubmatch(filename)
+// hourlyIndexLayout is the date part of hourly index names,
+// <baseIndexName>-2006-01-02-15.log.
+const hourlyIndexLayout = "2006-01-02-15"
 
-	if len(matches) != 4 {
-		return false, errors.New("invalid filename format")
+// isOlderThan reports whether the hour an index was created in, read from
+// its name after baseIndexName, started more than age ago.
+func isOlderThan(baseIndexName, filename string, age time.Duration) (bool, error) {
+	suffix, ok := strings.CutPrefix(filepath.Clean(filename), filepath.Clean(baseIndexName)+"-")
+	if !ok {
+		return false, fmt.Errorf("index name does not start with %v", baseIndexName)
 	}
-
-	// Parse year, month, and day from matches
-	year, _ := strconv.Atoi(matches[1])
-	month, _ := strconv.Atoi(matches[2])
-	day, _ := strconv.Atoi(matches[3])
-
-	// Create time.Time object for the extracted date
-	fileDate := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
-
-	// Get current time
-	now := time.Now().UTC()
-
-	// Calculate the difference
-	difference := now.Sub(fileDate)
-
-	return difference > age, nil
+	created, err := time.ParseInLocation(hourlyIndexLayout, strings.TrimSuffix(suffix, ".log"), time.Local)
+	if err != nil {
+		return false, fmt.Errorf("invalid index name: %w", err)
+	}
+	return time.Since(created) > age, nil
 }
 
 func (ilm *IndexLifecycleManager) indexCleanUp() error {
-	for _, index := range ilm.searchManager.indices {
-		delete, err := isOlderThan(index.Name(), ilm.retentionDays)
+	for _, index := range ilm.searchManager.list() {
+		delete, err := isOlderThan(ilm.baseIndexName, index.Name(), ilm.retentionDays)
 		if err != nil {
+			// keep cleaning up the other indexes
 			log.Printf("Cannot compare %v age. Error: %v", index.Name(), err)
-			return err
+			continue
 		}
 		if delete {
 			log.Printf("Removing %v from index alias.", index.Name())
-			ilm.indexSearch.Remove(index)
+			ilm.searchManager.remove(index)
 
 			log.Printf("Closing index %v", index.Name())
 			index.Close()
@@ -222,7 +428,9 @@ func (ilm *IndexLifecycleManager) indexCleanUp() error {
 
 func (ilm *IndexLifecycleManager) findAllIndexes() []string {
 	var indexList []string
-	matches, err := filepath.Glob(fmt.Sprintf("%v*.log", ilm.baseIndexName))
+	// match the date exactly, so the indexes of a tenant whose prefix
+	// starts with baseIndexName are not picked up
+	matches, err := filepath.Glob(ilm.baseIndexName + "-[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]-[0-9][0-9].log")
 	if err != nil {
 		log.Fatal(err)
 		return indexList
@@ -257,14 +465,17 @@ func (ilm IndexLifecycleManager) getIndexAlias() error {
 	indexList := ilm.findAllIndexes()
 
 	for _, index := range indexList {
+		// the active index is already open and in the alias
+		if ilm.index != nil && filepath.Clean(index) == filepath.Clean(ilm.index.Name()) {
+			continue
+		}
 		id, err := openIndexWithTimeout(index, 5*time.Second)
 		// log.Printf("var %v", id)
 		if err != nil {
 			log.Printf("Cannot open index. Error: %v", err)
 			continue
 		}
-		ilm.indexSearch.Add(id)
-		ilm.searchManager.indices[id.Name()] = id
+		ilm.searchManager.add(id)
 		log.Printf("Added index: %v to Index Alias", id.Name())
 	}
 	return nil
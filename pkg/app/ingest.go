@@ -0,0 +1,107 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/adiyakaihsan/go-logger/pkg/parser"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+const (
+	defaultSource   = "http"
+	maxBulkLineSize = 1 << 20
+)
+
+// errQueueUnavailable means logs could not be queued; clients should retry.
+var errQueueUnavailable = errors.New("cannot queue logs")
+
+// requestSource names the source of an HTTP ingest request, used to pick its
+// pipeline. It defaults to "http".
+func requestSource(r *http.Request) string {
+	if source := r.URL.Query().Get("source"); source != "" {
+		return source
+	}
+	return defaultSource
+}
+
+// requestFormat is the ?format= of the request, else the source's configured
+// format, else json.
+func (app App) requestFormat(r *http.Request, source string) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	if format := app.sources[source].Format; format != "" {
+		return format
+	}
+	return "json"
+}
+
+// streamFields identify the stream a log belongs to for multiline merging.
+var streamFields = []string{"host", "service", "container_name", "file", "stream"}
+
+func streamKey(source string, logs types.LogFormat) string {
+	key := logs.Tenant + "|" + source
+	for _, field := range streamFields {
+		value, _ := logs.GetString(field)
+		key += "|" + value
+	}
+	return key
+}
+
+// enqueue hands the log to the source's multiline aggregator, if any, and
+// otherwise processes it right away. The aggregator retries merged events it
+// cannot queue itself and only fails when it does not take the log.
+func (app App) enqueue(source string, logs types.LogFormat) error {
+	if aggregator, ok := app.multiline[source]; ok {
+		return aggregator.Add(streamKey(source, logs), logs, source)
+	}
+	return app.process(source, logs)
+}
+
+// process runs the source's pipeline and sampling, and queues the log unless
+// either dropped it.
+func (app App) process(source string, logs types.LogFormat) error {
+	if !app.pipelines.Process(source, &logs) {
+		return nil
+	}
+	if app.sampler != nil && !app.sampler.Sample(&logs) {
+		return nil
+	}
+	return app.queue.Enqueue(logs)
+}
+
+// ingestLines parses every non-empty line of body and queues the results for
+// tenant. Lines that do not parse are counted as failed; when a line cannot be
+// queued it stops with errQueueUnavailable.
+func (app App) ingestLines(body io.Reader, source, tenant string, p parser.Parser) (types.BulkResult, error) {
+	var result types.BulkResult
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBulkLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		logs, err := p.Parse(string(line))
+		if err != nil {
+			log.Printf("Cannot parse log. Error: %v", err)
+			result.Failed++
+			continue
+		}
+		logs.Tenant = tenant
+		if err := app.enqueue(source, logs); err != nil {
+			log.Printf("Cannot enqueue logs. Error: %v", err)
+			return result, fmt.Errorf("%w: %v", errQueueUnavailable, err)
+		}
+		result.Accepted++
+	}
+	return result, scanner.Err()
+}
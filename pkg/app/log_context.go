@@ -0,0 +1,207 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/numeric"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultContextSize = 20
+	maxContextSize     = 500
+)
+
+// defaultContextFields identify the source of a log. Neighbors must have the
+// same value in each of them that the log has.
+var defaultContextFields = []string{"host", "service"}
+
+var errLogNotFound = errors.New("log not found")
+
+// logContext returns the logs of the same source around a log, like
+// grep -C. The fields identifying the source can be changed with "by".
+func (app App) logContext(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	tenant, err := requestTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), tenantStatus(err))
+		return
+	}
+	before, err := contextSize(r, "before")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	after, err := contextSize(r, "after")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields := defaultContextFields
+	if by := r.URL.Query().Get("by"); by != "" {
+		fields = strings.Split(by, ",")
+	}
+
+	result, err := app.contextOf(r.Context(), tenant, ps.ByName("id"), fields, before, after)
+	if errors.Is(err, errLogNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Cannot get context of log %v. Error: %v", ps.ByName("id"), err)
+		http.Error(w, err.Error(), searchStatus(err))
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "Failed to marshal context", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resultJSON)
+}
+
+func contextSize(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultContextSize, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errors.New("Invalid " + name)
+	}
+	return min(n, maxContextSize), nil
+}
+
+// contextOf finds the log with id and up to before and after logs around it
+// that match it on fields. The log needs a text value in at least one of
+// them. The index alias spans every hourly index, so the
+// neighbors may come from other indexes.
+func (app App) contextOf(ctx context.Context, tenant, id string, fields []string, before, after int) (*types.ContextResponse, error) {
+	ilm, ok := app.tenants.Lookup(tenant)
+	if !ok {
+		return nil, errLogNotFound
+	}
+	searchCtx, done, err := app.searches.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	response, err := ilm.contextOf(searchCtx, id, fields, before, after)
+	if err != nil {
+		return nil, app.searches.searchError(ctx, searchCtx, err)
+	}
+	return response, nil
+}
+
+func (ilm *IndexLifecycleManager) contextOf(ctx context.Context, id string, fields []string, before, after int) (*types.ContextResponse, error) {
+
+	searchRequest := bleve.NewSearchRequest(bleve.NewDocIDQuery([]string{id}))
+	searchRequest.Size = 1
+	searchRequest.Fields = []string{"*"}
+	searchRequest.SortBy([]string{"timestamp", "_id"})
+	result, err := ilm.indexSearch.SearchInContext(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Hits) == 0 {
+		return nil, errLogNotFound
+	}
+	hit := newSearchResponse(result).Hits[0]
+	logs := hit.Log()
+	// the stored timestamp is cut to seconds, the indexed one is exact
+	timestamp, err := sortTimestamp(hit.Sort[0])
+	if err != nil {
+		return nil, err
+	}
+	anchor := hit.Sort
+	hit.Sort = nil
+
+	// A phrase of the value matches it whether the field was indexed as a
+	// keyword or as analyzed text, so neighbors are found in indexes created
+	// before and after the field became a keyword field.
+	var source []query.Query
+	for _, field := range fields {
+		value, ok := logs.Fields[field].(string)
+		if !ok || value == "" {
+			continue
+		}
+		phrase := bleve.NewMatchPhraseQuery(value)
+		phrase.SetField(types.IndexField(field))
+		source = append(source, phrase)
+	}
+	if len(source) == 0 {
+		return nil, fmt.Errorf("%w: the log has none of the fields %v, choose others with \"by\"", errInvalidSearch, strings.Join(fields, ", "))
+	}
+
+	response := &types.ContextResponse{Log: hit}
+	response.Before, err = ilm.neighbors(ctx, source, timestamp, anchor, false, before)
+	if err != nil {
+		return nil, err
+	}
+	response.After, err = ilm.neighbors(ctx, source, timestamp, anchor, true, after)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// neighbors returns the n logs matching source right after or before the
+// log with the (timestamp, _id) sort key anchor, in timestamp order. Logs
+// sharing the anchor's timestamp are ordered by ID, so none of them is
+// skipped and the anchor itself is left out.
+func (ilm *IndexLifecycleManager) neighbors(ctx context.Context, source []query.Query, timestamp time.Time, anchor []string, after bool, n int) ([]types.SearchHit, error) {
+	if n == 0 {
+		return []types.SearchHit{}, nil
+	}
+
+	inclusive := true
+	timeRange := bleve.NewDateRangeInclusiveQuery(time.Time{}, timestamp, nil, &inclusive)
+	order := []string{"-timestamp", "-_id"}
+	if after {
+		timeRange = bleve.NewDateRangeInclusiveQuery(timestamp, time.Time{}, &inclusive, nil)
+		order = []string{"timestamp", "_id"}
+	}
+	timeRange.SetField("timestamp")
+
+	searchRequest := bleve.NewSearchRequest(bleve.NewConjunctionQuery(append([]query.Query{timeRange}, source...)...))
+	searchRequest.Size = n
+	searchRequest.Fields = []string{"*"}
+	searchRequest.SortBy(order)
+	searchRequest.SetSearchAfter(anchor)
+
+	result, err := ilm.indexSearch.SearchInContext(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	hits := newSearchResponse(result).Hits
+	for i := range hits {
+		hits[i].Sort = nil
+	}
+	if !after {
+		for i, j := 0, len(hits)-1; i < j; i, j = i+1, j-1 {
+			hits[i], hits[j] = hits[j], hits[i]
+		}
+	}
+	return hits, nil
+}
+
+// sortTimestamp decodes the sort value of a hit sorted by timestamp.
+func sortTimestamp(value string) (time.Time, error) {
+	nanos, err := numeric.PrefixCoded(value).Int64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos).UTC(), nil
+}
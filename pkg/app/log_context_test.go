@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+func TestContextOfSharedTimestamp(t *testing.T) {
+	a, indexed := newTestApp(t)
+
+	// three logs of the api service share one timestamp with the anchor
+	base := time.Now().UTC().Truncate(time.Second)
+	stamps := []time.Time{base, base.Add(time.Second), base.Add(time.Second), base.Add(time.Second), base.Add(time.Second), base.Add(2 * time.Second)}
+	for _, ts := range stamps {
+		a.Ingest("http", types.LogFormat{Timestamp: ts, Level: "info", Message: "api", Fields: map[string]interface{}{"service": "api"}})
+	}
+	a.Ingest("http", types.LogFormat{Timestamp: base.Add(time.Second), Level: "info", Message: "worker", Fields: map[string]interface{}{"service": "worker"}})
+	waitIndexed(t, indexed, len(stamps)+1)
+
+	result, err := a.Search(context.Background(), "", types.SearchFormat{Query: "+fields.service:api"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(result.Hits) != len(stamps) {
+		t.Fatalf("got %d api logs, want %d", len(result.Hits), len(stamps))
+	}
+	// neighbors are ordered by timestamp, then ID
+	order := make([]types.SearchHit, len(result.Hits))
+	copy(order, result.Hits)
+	sort.Slice(order, func(i, j int) bool {
+		ti, tj := order[i].Log().Timestamp, order[j].Log().Timestamp
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return order[i].ID < order[j].ID
+	})
+
+	for anchor := 1; anchor <= 4; anchor++ {
+		response, err := a.contextOf(context.Background(), "", order[anchor].ID, []string{"service"}, 10, 10)
+		if err != nil {
+			t.Fatalf("contextOf: %v", err)
+		}
+		if got := ids(response.Before); !equalIDs(got, ids(order[:anchor])) {
+			t.Errorf("anchor %d: before = %v, want %v", anchor, got, ids(order[:anchor]))
+		}
+		if got := ids(response.After); !equalIDs(got, ids(order[anchor+1:])) {
+			t.Errorf("anchor %d: after = %v, want %v", anchor, got, ids(order[anchor+1:]))
+		}
+	}
+
+	// limits keep the closest neighbors
+	response, err := a.contextOf(context.Background(), "", order[2].ID, []string{"service"}, 1, 1)
+	if err != nil {
+		t.Fatalf("contextOf: %v", err)
+	}
+	if got, want := ids(append(response.Before, response.After...)), ids([]types.SearchHit{order[1], order[3]}); !equalIDs(got, want) {
+		t.Errorf("closest neighbors = %v, want %v", got, want)
+	}
+}
+
+func ids(hits []types.SearchHit) []string {
+	out := make([]string, len(hits))
+	for i, hit := range hits {
+		out[i] = hit.ID
+	}
+	return out
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
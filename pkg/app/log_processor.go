@@ -5,21 +5,38 @@ import (
 	"sync"
 
 	"github.com/adiyakaihsan/go-logger/pkg/queue"
+	"github.com/adiyakaihsan/go-logger/pkg/redact"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
 )
 
+// IndexHook is called once for every log taken from the queue, with its
+// document ID once indexed, or with the error that kept it from being
+// indexed.
+type IndexHook func(id string, logs types.LogFormat, err error)
+
 type LogProcessor struct {
-	queue queue.Queue
-	ilm   *IndexLifecycleManager
-	wg    sync.WaitGroup
+	queue    queue.Queue
+	tenants  *TenantManager
+	redactor *redact.Redactor
+	hooks    []IndexHook
+	wg       sync.WaitGroup
 }
 
-func NewLogProcessor(queue queue.Queue, ilm *IndexLifecycleManager) *LogProcessor {
+// NewLogProcessor creates a processor. redactor may be nil to index logs
+// unchanged.
+func NewLogProcessor(queue queue.Queue, tenants *TenantManager, redactor *redact.Redactor) *LogProcessor {
 	return &LogProcessor{
-		queue: queue,
-		ilm:   ilm,
+		queue:    queue,
+		tenants:  tenants,
+		redactor: redactor,
 	}
 }
 
+// AfterIndex registers hook. It must be called before Start.
+func (lp *LogProcessor) AfterIndex(hook IndexHook) {
+	lp.hooks = append(lp.hooks, hook)
+}
+
 func (lp *LogProcessor) Start() error {
 	go lp.processLogs()
 	log.Println("Log Processor Started.")
@@ -46,8 +63,23 @@ func (lp *LogProcessor) processLogs() {
 			lp.wg.Add(1)
 			go func() {
 				defer lp.wg.Done()
-				lp.ilm.indexWithRetry(logItem)
+				id, err := lp.index(&logItem)
+				for _, hook := range lp.hooks {
+					hook(id, logItem, err)
+				}
 			}()
 		}
 	}()
 }
+
+func (lp *LogProcessor) index(logItem *types.LogFormat) (string, error) {
+	if lp.redactor != nil {
+		lp.redactor.Redact(logItem)
+	}
+	ilm, err := lp.tenants.Get(logItem.Tenant)
+	if err != nil {
+		log.Printf("Cannot get index of tenant %q. Error: %v", logItem.Tenant, err)
+		return "", err
+	}
+	return ilm.indexWithRetry(*logItem)
+}
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/drain"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// maxPatternScan caps the logs mined per window.
+	maxPatternScan = 100000
+	// patternExamples is how many log IDs are kept per pattern and window.
+	patternExamples     = 3
+	defaultPatternLimit = 100
+	maxPatternLimit     = 10000
+)
+
+// patterns mines the message templates of a search, see package drain.
+func (app App) patterns(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tenant, err := requestTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), tenantStatus(err))
+		return
+	}
+	var patternsFormat types.PatternsFormat
+	if err := json.NewDecoder(r.Body).Decode(&patternsFormat); err != nil {
+		http.Error(w, "Invalid patterns request", http.StatusBadRequest)
+		return
+	}
+
+	result, err := app.minePatterns(r.Context(), tenant, patternsFormat)
+	if err != nil {
+		log.Printf("Cannot mine patterns: %v, Error: %v", patternsFormat.Query, err)
+		http.Error(w, err.Error(), searchStatus(err))
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "Failed to marshal patterns", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resultJSON)
+}
+
+// patternStats is what one window saw of a pattern.
+type patternStats struct {
+	count     int
+	examples  []string
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// patternWindow is the result of mining one window.
+type patternWindow struct {
+	stats          map[int]*patternStats
+	scanned        int
+	truncated      bool
+	skippedIndexes int
+}
+
+// minePatterns feeds the messages of the baseline window, if any, and then
+// of the requested window through one miner, so both windows share their
+// templates and can be compared pattern by pattern.
+func (app App) minePatterns(ctx context.Context, tenant string, patternsFormat types.PatternsFormat) (*types.PatternsResponse, error) {
+	started := time.Now()
+	limit := patternsFormat.Limit
+	if limit < 0 || limit > maxPatternLimit {
+		return nil, fmt.Errorf("%w: limit must be between 0 and %d", errInvalidSearch, maxPatternLimit)
+	}
+	if limit == 0 {
+		limit = defaultPatternLimit
+	}
+	baseline := patternsFormat.Baseline
+	if baseline != nil && (baseline.Start.IsZero() || !baseline.Start.Before(baseline.End)) {
+		return nil, fmt.Errorf("%w: baseline needs a start before its end", errInvalidSearch)
+	}
+
+	searchCtx, done, err := app.searches.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	miner := drain.New(drain.Options{})
+	response := &types.PatternsResponse{}
+	var before *patternWindow
+	if baseline != nil {
+		before, err = app.mineWindow(searchCtx, tenant, miner, types.SearchFormat{Query: patternsFormat.Query, Start: baseline.Start, End: baseline.End})
+		if err != nil {
+			return nil, app.searches.searchError(ctx, searchCtx, err)
+		}
+		response.BaselineScanned = before.scanned
+	}
+	current, err := app.mineWindow(searchCtx, tenant, miner, types.SearchFormat{Query: patternsFormat.Query, Start: patternsFormat.Start, End: patternsFormat.End})
+	if err != nil {
+		return nil, app.searches.searchError(ctx, searchCtx, err)
+	}
+	response.Scanned = current.scanned
+	response.SkippedIndexes = current.skippedIndexes
+	response.Truncated = current.truncated || before != nil && before.truncated
+
+	clusters := miner.Clusters()
+	response.Patterns = make([]types.Pattern, 0, len(current.stats))
+	for id, stats := range current.stats {
+		pattern := newPattern(clusters[id], stats)
+		if before != nil {
+			baselineCount := 0
+			if stats, ok := before.stats[id]; ok {
+				baselineCount = stats.count
+			}
+			pattern.BaselineCount = &baselineCount
+			pattern.New = baselineCount == 0
+		}
+		response.Patterns = append(response.Patterns, pattern)
+	}
+	if before != nil {
+		for id, stats := range before.stats {
+			if _, ok := current.stats[id]; !ok {
+				response.Gone = append(response.Gone, newPattern(clusters[id], stats))
+			}
+		}
+	}
+
+	var cut bool
+	response.Patterns, cut = topPatterns(response.Patterns, limit)
+	response.Truncated = response.Truncated || cut
+	response.Gone, cut = topPatterns(response.Gone, limit)
+	response.Truncated = response.Truncated || cut
+	response.Took = time.Since(started)
+	return response, nil
+}
+
+// mineWindow adds the messages matching searchFormat to miner and counts
+// them per cluster.
+func (app App) mineWindow(ctx context.Context, tenant string, miner *drain.Miner, searchFormat types.SearchFormat) (*patternWindow, error) {
+	q, err := searchQuery(searchFormat)
+	if err != nil {
+		return nil, err
+	}
+	window := &patternWindow{stats: map[int]*patternStats{}}
+	ilm, ok := app.tenants.Lookup(tenant)
+	if !ok {
+		return window, nil
+	}
+	ilm, window.skippedIndexes = ilm.pruned(searchFormat.Start, searchFormat.End)
+	err = ilm.scan(ctx, q, exportPageSize, func(id string, logs types.LogFormat) error {
+		if window.scanned == maxPatternScan {
+			window.truncated = true
+			return errStopScan
+		}
+		window.scanned++
+		cluster := miner.Add(logs.Message)
+		if cluster == nil {
+			// too many clusters, the message fits none of them
+			window.truncated = true
+			return nil
+		}
+		stats, ok := window.stats[cluster.ID]
+		if !ok {
+			stats = &patternStats{firstSeen: logs.Timestamp}
+			window.stats[cluster.ID] = stats
+		}
+		stats.count++
+		stats.lastSeen = logs.Timestamp
+		if len(stats.examples) < patternExamples {
+			stats.examples = append(stats.examples, id)
+		}
+		return nil
+	})
+	return window, err
+}
+
+func newPattern(cluster *drain.Cluster, stats *patternStats) types.Pattern {
+	return types.Pattern{
+		Template:   cluster.Template(),
+		Count:      stats.count,
+		ExampleIDs: stats.examples,
+		FirstSeen:  stats.firstSeen,
+		LastSeen:   stats.lastSeen,
+	}
+}
+
+// topPatterns sorts patterns by count, most frequent first, and keeps at
+// most limit of them.
+func topPatterns(patterns []types.Pattern, limit int) ([]types.Pattern, bool) {
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Count != patterns[j].Count {
+			return patterns[i].Count > patterns[j].Count
+		}
+		return patterns[i].FirstSeen.Before(patterns[j].FirstSeen)
+	})
+	if len(patterns) > limit {
+		return patterns[:limit], true
+	}
+	return patterns, false
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+func TestMinePatternsBaseline(t *testing.T) {
+	tenants, err := NewTenantManager(filepath.Join(t.TempDir(), "index"), time.Hour, nil, config.IndexConfig{})
+	if err != nil {
+		t.Fatalf("NewTenantManager: %v", err)
+	}
+	defer tenants.Close()
+	searches, err := newSearchLimits(config.SearchConfig{})
+	if err != nil {
+		t.Fatalf("newSearchLimits: %v", err)
+	}
+	app := App{tenants: tenants, searches: searches}
+
+	ilm, err := tenants.Get("")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	deploy := time.Now().Truncate(time.Minute)
+	for _, logs := range []struct {
+		offset  time.Duration
+		message string
+	}{
+		{-30 * time.Minute, "user 1 logged in"},
+		{-20 * time.Minute, "user 2 logged in"},
+		{-10 * time.Minute, "cache miss for sessions"},
+		{0, "user 3 logged in"},
+		{10 * time.Minute, "disk full on sda"},
+		{20 * time.Minute, "disk full on sdb"},
+		{30 * time.Minute, "disk full on sdc"},
+	} {
+		entry := types.LogFormat{Timestamp: deploy.Add(logs.offset), Level: "info", Message: logs.message}
+		if _, err := ilm.indexWithRetry(entry); err != nil {
+			t.Fatalf("index: %v", err)
+		}
+	}
+
+	result, err := app.minePatterns(context.Background(), "", types.PatternsFormat{
+		Start:    deploy,
+		End:      deploy.Add(time.Hour),
+		Baseline: &types.TimeWindow{Start: deploy.Add(-time.Hour), End: deploy},
+	})
+	if err != nil {
+		t.Fatalf("minePatterns: %v", err)
+	}
+
+	if result.Scanned != 4 || result.BaselineScanned != 3 {
+		t.Errorf("scanned %d and %d baseline logs, want 4 and 3", result.Scanned, result.BaselineScanned)
+	}
+	type pattern struct {
+		template      string
+		count         int
+		baselineCount int
+		new           bool
+	}
+	var got []pattern
+	for _, p := range result.Patterns {
+		if p.BaselineCount == nil {
+			t.Fatalf("pattern %q has no baseline count", p.Template)
+		}
+		got = append(got, pattern{p.Template, p.Count, *p.BaselineCount, p.New})
+	}
+	want := []pattern{
+		{"disk full on <*>", 3, 0, true},
+		{"user <*> logged in", 1, 2, false},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("patterns = %+v, want %+v", got, want)
+	}
+	if len(result.Gone) != 1 || result.Gone[0].Template != "cache miss for sessions" || result.Gone[0].Count != 1 {
+		t.Errorf("gone = %+v, want the cache miss pattern once", result.Gone)
+	}
+	if len(result.Patterns[0].ExampleIDs) != patternExamples {
+		t.Errorf("%d example IDs, want %d", len(result.Patterns[0].ExampleIDs), patternExamples)
+	}
+}
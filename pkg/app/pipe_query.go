@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/pipeql"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// maxPipeScan caps the logs a pipe query reads from the indexes.
+	maxPipeScan = 1000000
+	// maxPipeRows caps the rows a pipe query returns.
+	maxPipeRows = 10000
+)
+
+// pipeQuery runs a pipe query, see package pipeql.
+func (app App) pipeQuery(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tenant, err := requestTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), tenantStatus(err))
+		return
+	}
+	var pipeQuery types.PipeQueryFormat
+	if err := json.NewDecoder(r.Body).Decode(&pipeQuery); err != nil {
+		http.Error(w, "Invalid query request", http.StatusBadRequest)
+		return
+	}
+
+	result, err := app.runPipeQuery(r.Context(), tenant, pipeQuery)
+	if err != nil {
+		log.Printf("Cannot run pipe query: %v, Error: %v", pipeQuery.Query, err)
+		http.Error(w, err.Error(), searchStatus(err))
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, "Failed to marshal query results", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resultJSON)
+}
+
+// runPipeQuery pushes the search stage down to the indexes and streams the
+// matches through the other stages in timestamp order, stopping as soon as
+// the stages need no more.
+func (app App) runPipeQuery(ctx context.Context, tenant string, pipeQuery types.PipeQueryFormat) (*types.PipeQueryResponse, error) {
+	started := time.Now()
+	plan, err := pipeql.Compile(pipeQuery.Query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidSearch, err)
+	}
+	q, err := searchQuery(types.SearchFormat{Query: plan.Search, Start: pipeQuery.Start, End: pipeQuery.End})
+	if err != nil {
+		return nil, err
+	}
+
+	// a pipe query scans every match like an export, so it takes an export
+	// slot and the export timeout
+	exportCtx, done, err := app.searches.beginExport(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	response := &types.PipeQueryResponse{}
+	execution := plan.Execute(maxPipeRows)
+	if ilm, ok := app.tenants.Lookup(tenant); ok {
+		ilm, response.SkippedIndexes = ilm.pruned(pipeQuery.Start, pipeQuery.End)
+		pageSize := exportPageSize
+		if plan.Limit > 0 {
+			pageSize = min(plan.Limit, pageSize)
+		}
+		err = ilm.scan(exportCtx, q, pageSize, func(_ string, logs types.LogFormat) error {
+			if response.Scanned == maxPipeScan {
+				response.Truncated = true
+				return errStopScan
+			}
+			response.Scanned++
+			if !execution.Push(logRow(logs)) {
+				return errStopScan
+			}
+			return nil
+		})
+		// on timeout the rows of the logs scanned so far are returned
+		err = app.searches.exportError(ctx, exportCtx, err)
+		if errors.Is(err, errSearchTimeout) {
+			response.Truncated = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, truncated := execution.Finish()
+	response.Rows = make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		response.Rows[i] = row
+	}
+	response.Truncated = response.Truncated || truncated
+	response.Took = time.Since(started)
+	return response, nil
+}
+
+// logRow flattens a log into a pipe query row.
+func logRow(logs types.LogFormat) pipeql.Row {
+	row := pipeql.Row{
+		"timestamp": logs.Timestamp,
+		"level":     logs.Level,
+		"message":   logs.Message,
+	}
+	if logs.Tenant != "" {
+		row["tenant"] = logs.Tenant
+	}
+	if logs.SampleRate != 0 {
+		row["sample_rate"] = logs.SampleRate
+	}
+	for name, value := range logs.Fields {
+		if _, ok := row[name]; !ok {
+			row[name] = value
+		}
+	}
+	return row
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+func TestRunPipeQueryLimits(t *testing.T) {
+	a, indexed := newTestApp(t)
+	for _, level := range []string{"error", "error", "info"} {
+		a.Ingest("http", types.LogFormat{Timestamp: time.Now(), Level: level, Message: "request"})
+	}
+	waitIndexed(t, indexed, 3)
+	ctx := context.Background()
+	query := types.PipeQueryFormat{Query: "level:error | stats count()"}
+
+	// pipe queries use the export slots, not the interactive search ones
+	for i := 0; i < cap(a.searches.slots); i++ {
+		a.searches.slots <- struct{}{}
+	}
+	result, err := a.runPipeQuery(ctx, "", query)
+	if err != nil {
+		t.Fatalf("runPipeQuery with busy search slots: %v", err)
+	}
+	if len(result.Rows) != 1 || fmt.Sprint(result.Rows[0]["count"]) != "2" || result.Truncated {
+		t.Errorf("result = %+v, want a count of 2", result)
+	}
+	for i := 0; i < cap(a.searches.slots); i++ {
+		<-a.searches.slots
+	}
+
+	for i := 0; i < cap(a.searches.exportSlots); i++ {
+		a.searches.exportSlots <- struct{}{}
+	}
+	if _, err := a.runPipeQuery(ctx, "", query); !errors.Is(err, errTooManySearches) {
+		t.Errorf("runPipeQuery with busy export slots = %v, want %v", err, errTooManySearches)
+	}
+	for i := 0; i < cap(a.searches.exportSlots); i++ {
+		<-a.searches.exportSlots
+	}
+
+	// a timed out query returns what it has scanned so far
+	a.searches.exportTimeout = time.Nanosecond
+	result, err = a.runPipeQuery(ctx, "", query)
+	if err != nil {
+		t.Fatalf("runPipeQuery after the export timeout: %v", err)
+	}
+	if !result.Truncated {
+		t.Errorf("result = %+v, want truncated", result)
+	}
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/adiyakaihsan/go-logger/pkg/auth"
+	"github.com/adiyakaihsan/go-logger/pkg/ratelimit"
+	"github.com/julienschmidt/httprouter"
+)
+
+// limitKey picks the rate limit key according to the configured dimension.
+// keyName is the API key of the caller, empty when unauthenticated.
+func (app App) limitKey(keyName, source, tenant string) string {
+	switch app.limiter.By() {
+	case "api_key":
+		if keyName != "" {
+			return keyName
+		}
+		return "anonymous"
+	case "source":
+		return source
+	default:
+		if tenant == "" {
+			return "default"
+		}
+		return tenant
+	}
+}
+
+// allowIngest applies rate limits and quotas to an ingest request carrying
+// records logs in body. It sets the limit headers and answers 429 when the
+// request is rejected, or 413 when it holds more records than the burst.
+func (app App) allowIngest(w http.ResponseWriter, r *http.Request, source, tenant string, records int64, body []byte) bool {
+	if app.limiter == nil {
+		return true
+	}
+
+	var keyName string
+	if id, ok := auth.FromContext(r.Context()); ok {
+		keyName = id.Name
+	}
+	d := app.limiter.Allow(app.limitKey(keyName, source, tenant), records, int64(len(body)))
+
+	if d.Limit.RecordsPerSecond > 0 {
+		w.Header().Set("X-RateLimit-Limit", strconv.FormatFloat(d.Limit.RecordsPerSecond, 'f', -1, 64))
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(d.Remaining, 10))
+	}
+	if d.Limit.DailyRecords > 0 {
+		w.Header().Set("X-Quota-Records-Limit", strconv.FormatInt(d.Limit.DailyRecords, 10))
+		w.Header().Set("X-Quota-Records-Remaining", strconv.FormatInt(d.RecordsRemaining, 10))
+	}
+	if d.Limit.DailyBytes > 0 {
+		w.Header().Set("X-Quota-Bytes-Limit", strconv.FormatInt(d.Limit.DailyBytes, 10))
+		w.Header().Set("X-Quota-Bytes-Remaining", strconv.FormatInt(d.BytesRemaining, 10))
+	}
+	if d.Allowed {
+		return true
+	}
+	if d.TooLarge {
+		http.Error(w, fmt.Sprintf("Request too large: %s", d.Reason), http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+	http.Error(w, fmt.Sprintf("Too many requests: %s", d.Reason), http.StatusTooManyRequests)
+	return false
+}
+
+// ingestRecords is the number of records an ingest body is charged for: one
+// for a single JSON document, however it is formatted, and one per non-empty
+// line for the line based formats.
+func ingestRecords(format string, body []byte) int64 {
+	if format == "json" {
+		return 1
+	}
+	return int64(countLines(body))
+}
+
+// countLines counts the non-empty lines of a request body.
+func countLines(body []byte) int {
+	count := 0
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+type usageResponse struct {
+	By    string                     `json:"by"`
+	Usage map[string]ratelimit.Usage `json:"usage"`
+}
+
+// ingestUsage reports today's ingest usage per rate limit key.
+func (app App) ingestUsage(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	resp := usageResponse{Usage: map[string]ratelimit.Usage{}}
+	if app.limiter != nil {
+		resp.By = app.limiter.By()
+		resp.Usage = app.limiter.Usage()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
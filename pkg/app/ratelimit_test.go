@@ -0,0 +1,46 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/ratelimit"
+)
+
+func TestIngestCharging(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		body      string
+		code      int
+		remaining string
+	}{
+		{"single json", "json", "{\"level\": \"info\", \"message\": \"one\"}", http.StatusOK, "2"},
+		{"pretty printed json", "json", "{\n  \"level\": \"info\",\n  \"message\": \"one\",\n  \"service\": \"api\"\n}\n", http.StatusOK, "2"},
+		{"raw lines", "raw", "one\n\ntwo\nthree\n", http.StatusOK, "0"},
+		{"more lines than the burst", "logfmt", "msg=1\nmsg=2\nmsg=3\nmsg=4\n", http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter, err := ratelimit.New(config.RateLimitConfig{Default: config.Limit{RecordsPerSecond: 1, Burst: 3}})
+			if err != nil {
+				t.Fatalf("ratelimit.New: %v", err)
+			}
+			app := App{limiter: limiter}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/log/ingest", nil)
+			body := []byte(tt.body)
+			allowed := app.allowIngest(w, r, "http", "", ingestRecords(tt.format, body), body)
+
+			if allowed != (tt.code == http.StatusOK) || w.Code != tt.code {
+				t.Errorf("allowed = %v, status = %d, want %d", allowed, w.Code, tt.code)
+			}
+			if got := w.Header().Get("X-RateLimit-Remaining"); tt.remaining != "" && got != tt.remaining {
+				t.Errorf("remaining = %q, want %q", got, tt.remaining)
+			}
+		})
+	}
+}
@@ -1,41 +1,200 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/adiyakaihsan/go-logger/pkg/types"
 	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	// registers the "ansi" highlight style, "html" is built in
+	_ "github.com/blevesearch/bleve/v2/search/highlight/highlighter/ansi"
 )
 
-// TO DO: Add handler to handle search with time range
-// func (app App) searchWithRange(startTime time.Time, endTime time.Time) (*bleve.SearchResult, error) {
-// 	rangeQuery := bleve.NewDateRangeQuery(startTime, endTime)
-// 	rangeQuery.SetField("timestamp")
+// errInvalidSearch marks errors caused by the search request itself.
+var errInvalidSearch = errors.New("invalid search")
+
+// searchQuery builds the bleve query of a search: its query string and DSL
+// query, or every log when both are empty, restricted to the time range if
+// one is given.
+func searchQuery(searchFormat types.SearchFormat) (query.Query, error) {
+	var queries []query.Query
+	if searchFormat.Query != "" {
+		queryString := bleve.NewQueryStringQuery(searchFormat.Query)
+		if _, err := queryString.Parse(); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidSearch, err)
+		}
+		queries = append(queries, queryString)
+	}
+	if searchFormat.DSL != nil {
+		dsl, err := dslQuery(*searchFormat.DSL, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%w: dsl: %v", errInvalidSearch, err)
+		}
+		queries = append(queries, dsl)
+	}
+	if !searchFormat.Start.IsZero() || !searchFormat.End.IsZero() {
+		inclusive, exclusive := true, false
+		timeRange := bleve.NewDateRangeInclusiveQuery(searchFormat.Start, searchFormat.End, &inclusive, &exclusive)
+		timeRange.SetField("timestamp")
+		queries = append(queries, timeRange)
+	}
+
+	switch len(queries) {
+	case 0:
+		return bleve.NewMatchAllQuery(), nil
+	case 1:
+		return queries[0], nil
+	default:
+		return bleve.NewConjunctionQuery(queries...), nil
+	}
+}
+
+// searchWithQuery searches the indexes of tenant only. Tenants that never
+// ingested anything get an empty result. The search is cancelled with ctx
+// and bounded by the search limits.
+func (app App) searchWithQuery(ctx context.Context, tenant string, searchFormat types.SearchFormat) (*types.SearchResponse, error) {
+	size, err := app.searches.window(searchFormat.From, searchFormat.Size)
+	if err != nil {
+		return nil, err
+	}
+	q, err := searchQuery(searchFormat)
+	if err != nil {
+		return nil, err
+	}
+	ilm, ok := app.tenants.Lookup(tenant)
+	if !ok {
+		return &types.SearchResponse{Hits: []types.SearchHit{}}, nil
+	}
 
-// 	searchRequest := bleve.NewSearchRequest(rangeQuery)
-// 	searchRequest.Fields = []string{"timestamp", "level", "message"}
-// 	searchResults, err := app.ilm.indexSearch.Search(searchRequest)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return searchResults, nil
+	searchCtx, done, err := app.searches.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	ilm, skipped := ilm.pruned(searchFormat.Start, searchFormat.End)
+	response, err := ilm.search(searchCtx, q, searchFormat, size)
+	if err != nil {
+		return nil, app.searches.searchError(ctx, searchCtx, err)
+	}
+	response.SkippedIndexes = skipped
+	return response, nil
+}
 
-// }
+func (ilm *IndexLifecycleManager) search(ctx context.Context, q query.Query, searchFormat types.SearchFormat, size int) (*types.SearchResponse, error) {
+	var err error
+	searchRequest := bleve.NewSearchRequestOptions(q, size, searchFormat.From, false)
 
-func (app App) searchWithQuery(searchQuery types.SearchFormat) (*bleve.SearchResult, error) {
-	query := bleve.NewQueryStringQuery(searchQuery.Query)
-	searchRequest := bleve.NewSearchRequest(query)
-	searchRequest.Size = 100
+	searchRequest.Fields = []string{"*"}
+	if searchFormat.Highlight != nil {
+		searchRequest.Highlight, err = newHighlight(*searchFormat.Highlight)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidSearch, err)
+		}
+	}
 
-	searchRequest.Fields = []string{"timestamp", "level", "message"}
+	plans, err := planAggregations(searchFormat.Aggregations, ilm.timeBounds(ctx, q, searchFormat), 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidSearch, err)
+	}
+	addFacets(searchRequest, plans)
 
 	//search Index Alias indexSearch
-	searchResults, err := app.ilm.indexSearch.Search(searchRequest)
+	searchResults, err := ilm.indexSearch.SearchInContext(ctx, searchRequest)
 	if err != nil {
 		return nil, err
 	}
-
-	// combinedHits := append(activeIndexSearchResults.Hits, indexAliasSearchResults.Hits...)
 	log.Printf("Found %v document match!", searchResults.Hits.Len())
-	return searchResults, err
+
+	response := newSearchResponse(searchResults)
+	response.Aggregations, err = ilm.aggregationResults(ctx, q, plans, searchResults.Facets)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// newHighlight highlights message and the requested fields.
+func newHighlight(h types.Highlight) (*bleve.HighlightRequest, error) {
+	style := h.Style
+	switch style {
+	case "":
+		style = types.HighlightHTML
+	case types.HighlightHTML, types.HighlightANSI:
+	default:
+		return nil, fmt.Errorf("unknown highlight style %q", h.Style)
+	}
+
+	highlight := bleve.NewHighlightWithStyle(style)
+	highlight.AddField("message")
+	for _, field := range h.Fields {
+		if field = types.IndexField(field); field != "message" {
+			highlight.AddField(field)
+		}
+	}
+	return highlight, nil
+}
+
+// timeBounds returns the requested time range, filling open ends with the
+// oldest and newest matching timestamps. It searches at most once.
+func (ilm *IndexLifecycleManager) timeBounds(ctx context.Context, q query.Query, searchFormat types.SearchFormat) timeBounds {
+	var start, end time.Time
+	var err error
+	var done bool
+
+	return func() (time.Time, time.Time, error) {
+		if done {
+			return start, end, err
+		}
+		done = true
+
+		start, end = searchFormat.Start, searchFormat.End
+		if start.IsZero() {
+			start, err = ilm.edgeTimestamp(ctx, q, "timestamp")
+		}
+		if end.IsZero() && err == nil {
+			end, err = ilm.edgeTimestamp(ctx, q, "-timestamp")
+			end = end.Add(time.Nanosecond)
+		}
+		return start, end, err
+	}
+}
+
+// edgeTimestamp returns the timestamp of the first match in order.
+func (ilm *IndexLifecycleManager) edgeTimestamp(ctx context.Context, q query.Query, order string) (time.Time, error) {
+	searchRequest := bleve.NewSearchRequestOptions(q, 1, 0, false)
+	searchRequest.Fields = []string{"timestamp"}
+	searchRequest.SortBy([]string{order})
+
+	result, err := ilm.indexSearch.SearchInContext(ctx, searchRequest)
+	if err != nil || len(result.Hits) == 0 {
+		return time.Time{}, err
+	}
+	value, _ := result.Hits[0].Fields["timestamp"].(string)
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// newSearchResponse converts a bleve result into the stable response shape.
+func newSearchResponse(result *bleve.SearchResult) types.SearchResponse {
+	response := types.SearchResponse{
+		Hits:      make([]types.SearchHit, 0, len(result.Hits)),
+		TotalHits: result.Total,
+		MaxScore:  result.MaxScore,
+		Took:      result.Took,
+	}
+	for _, hit := range result.Hits {
+		response.Hits = append(response.Hits, types.SearchHit{
+			ID:        hit.ID,
+			Index:     hit.Index,
+			Score:     hit.Score,
+			Sort:      hit.Sort,
+			Fields:    hit.Fields,
+			Fragments: hit.Fragments,
+		})
+	}
+	return response
 }
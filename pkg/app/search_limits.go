@@ -0,0 +1,140 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+)
+
+const (
+	defaultSearchTimeout   = 30 * time.Second
+	defaultMaxResultWindow = 10000
+	defaultMaxSearches     = 16
+	defaultSearchSize      = 100
+	defaultExportTimeout   = 10 * time.Minute
+	defaultMaxExports      = 4
+)
+
+var (
+	errTooManySearches = errors.New("too many concurrent searches, try again later")
+	errSearchTimeout   = errors.New("search timed out")
+)
+
+// searchLimits bounds how many searches run at once and how long and how
+// deep each may go. Exports, which stream every match to the client, have
+// their own slots and timeout, so slow readers cannot starve searches.
+type searchLimits struct {
+	timeout         time.Duration
+	maxResultWindow int
+	slots           chan struct{}
+	exportTimeout   time.Duration
+	exportSlots     chan struct{}
+}
+
+func newSearchLimits(cfg config.SearchConfig) (*searchLimits, error) {
+	if cfg.Timeout < 0 || cfg.MaxResultWindow < 0 || cfg.MaxConcurrent < 0 || cfg.ExportTimeout < 0 || cfg.MaxConcurrentExports < 0 {
+		return nil, fmt.Errorf("search limits cannot be negative")
+	}
+	limits := &searchLimits{
+		timeout:         time.Duration(cfg.Timeout),
+		maxResultWindow: cfg.MaxResultWindow,
+		exportTimeout:   time.Duration(cfg.ExportTimeout),
+	}
+	if limits.timeout == 0 {
+		limits.timeout = defaultSearchTimeout
+	}
+	if limits.maxResultWindow == 0 {
+		limits.maxResultWindow = defaultMaxResultWindow
+	}
+	if limits.exportTimeout == 0 {
+		limits.exportTimeout = defaultExportTimeout
+	}
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent == 0 {
+		maxConcurrent = defaultMaxSearches
+	}
+	maxExports := cfg.MaxConcurrentExports
+	if maxExports == 0 {
+		maxExports = defaultMaxExports
+	}
+	limits.slots = make(chan struct{}, maxConcurrent)
+	limits.exportSlots = make(chan struct{}, maxExports)
+	return limits, nil
+}
+
+// acquire takes one of slots without waiting. release must be called when
+// the search is done.
+func acquire(slots chan struct{}) (release func(), err error) {
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	default:
+		return nil, errTooManySearches
+	}
+}
+
+// bound takes one of slots and bounds ctx by timeout. done releases both.
+func bound(ctx context.Context, slots chan struct{}, timeout time.Duration) (boundCtx context.Context, done func(), err error) {
+	release, err := acquire(slots)
+	if err != nil {
+		return nil, nil, err
+	}
+	boundCtx, cancel := context.WithTimeout(ctx, timeout)
+	return boundCtx, func() {
+		cancel()
+		release()
+	}, nil
+}
+
+// begin takes a search slot and bounds ctx by the search timeout. done
+// releases both.
+func (l *searchLimits) begin(ctx context.Context) (searchCtx context.Context, done func(), err error) {
+	return bound(ctx, l.slots, l.timeout)
+}
+
+// beginExport takes an export slot and bounds ctx by the export timeout.
+func (l *searchLimits) beginExport(ctx context.Context) (exportCtx context.Context, done func(), err error) {
+	return bound(ctx, l.exportSlots, l.exportTimeout)
+}
+
+// window checks from and size against the result window and fills in the
+// default size, shrunk to fit the window.
+func (l *searchLimits) window(from, size int) (int, error) {
+	if from < 0 || size < 0 {
+		return 0, fmt.Errorf("%w: from and size cannot be negative", errInvalidSearch)
+	}
+	if size == 0 {
+		size = defaultSearchSize
+		if from < l.maxResultWindow {
+			size = min(size, l.maxResultWindow-from)
+		}
+	}
+	if from+size > l.maxResultWindow {
+		return 0, fmt.Errorf("%w: from + size must be at most %d, use export for larger results", errInvalidSearch, l.maxResultWindow)
+	}
+	return size, nil
+}
+
+// searchError tells a timeout of the search itself apart from other errors
+// once searchCtx, derived from ctx by begin, has ended.
+func (l *searchLimits) searchError(ctx, searchCtx context.Context, err error) error {
+	return timeoutError(ctx, searchCtx, err, l.timeout)
+}
+
+// exportError is searchError for a context made by beginExport.
+func (l *searchLimits) exportError(ctx, exportCtx context.Context, err error) error {
+	return timeoutError(ctx, exportCtx, err, l.exportTimeout)
+}
+
+func timeoutError(ctx, boundCtx context.Context, err error, timeout time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == nil && errors.Is(boundCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %v", errSearchTimeout, timeout)
+	}
+	return err
+}
@@ -4,15 +4,25 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 
+	"github.com/adiyakaihsan/go-logger/pkg/auth"
+	"github.com/adiyakaihsan/go-logger/pkg/tlsconfig"
 	"github.com/julienschmidt/httprouter"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
 	server *http.Server
 	router *httprouter.Router
 	app    *App
+	auth   *auth.Store
+
+	grpc     *grpc.Server
+	grpcPort string
+
+	requireClientCert bool
 }
 
 func NewServer(cfg Config) *Server {
@@ -21,37 +31,129 @@ func NewServer(cfg Config) *Server {
 	if err != nil {
 		log.Fatalf("Cannot instantiate App. Error: %v", err)
 	}
+	var keys *auth.Store
+	if cfg.AuthKeysFile != "" {
+		keys, err = auth.LoadStore(cfg.AuthKeysFile)
+		if err != nil {
+			log.Fatalf("Cannot load API keys. Error: %v", err)
+		}
+	}
+
+	if cfg.RequireClientCert && cfg.TLS.ClientCAFile == "" {
+		log.Fatal("Requiring client certificates needs a client CA file")
+	}
+	if err := cfg.TLS.Validate(); err != nil {
+		log.Fatalf("Cannot configure TLS. Error: %v", err)
+	}
+
 	server := &Server{
 		router: router,
 		server: &http.Server{
 			Addr:    fmt.Sprintf(":%s", cfg.Port),
 			Handler: router,
 		},
-		app: app,
+		app:  app,
+		auth: keys,
+
+		requireClientCert: cfg.RequireClientCert,
+	}
+	if cfg.TLS.Enabled() {
+		tlsConfig, err := tlsconfig.Server(cfg.TLS)
+		if err != nil {
+			log.Fatalf("Cannot configure TLS. Error: %v", err)
+		}
+		server.server.TLSConfig = tlsConfig
+	}
+	if cfg.GRPCPort != "" {
+		server.grpc = newGRPCServer(app, keys, server.server.TLSConfig, cfg.RequireClientCert)
+		server.grpcPort = cfg.GRPCPort
 	}
 	server.registerRoutes()
 	return server
 }
 
 func (s *Server) registerRoutes() {
-	s.router.POST("/api/v1/log/ingest", s.app.ingester)
-	s.router.POST("/api/v1/log/search", s.app.search)
+	s.router.POST("/api/v1/log/ingest", s.ingest(s.app.ingester))
+	s.router.POST("/api/v1/log/bulk", s.ingest(s.app.bulkIngester))
+	s.router.POST("/api/v1/log/search", s.require(auth.RoleRead, s.app.search))
+	s.router.POST("/api/v1/log/query", s.require(auth.RoleRead, s.app.pipeQuery))
+	s.router.POST("/api/v1/log/patterns", s.require(auth.RoleRead, s.app.patterns))
+	s.router.POST("/api/v1/log/export", s.require(auth.RoleRead, s.app.export))
+	s.router.GET("/api/v1/log/context/:id", s.require(auth.RoleRead, s.app.logContext))
+	s.router.GET("/api/v1/log/tail", s.require(auth.RoleRead, s.app.tailLogs))
+	s.router.GET("/api/v1/admin/redactions", s.require(auth.RoleAdmin, s.app.redactionStats))
+	s.router.GET("/api/v1/admin/multiline", s.require(auth.RoleAdmin, s.app.multilineStats))
+	s.router.GET("/api/v1/admin/usage", s.require(auth.RoleAdmin, s.app.ingestUsage))
+}
+
+// require protects a route with role when API keys are configured. Without
+// a key file the API stays open.
+func (s *Server) require(role auth.Role, handle httprouter.Handle) httprouter.Handle {
+	if s.auth == nil {
+		return handle
+	}
+	return s.auth.Require(role, handle)
+}
+
+// ingest protects an ingest route with the ingest role and, if configured,
+// a verified client certificate.
+func (s *Server) ingest(handle httprouter.Handle) httprouter.Handle {
+	if s.requireClientCert {
+		handle = tlsconfig.RequireClientCert(handle)
+	}
+	return s.require(auth.RoleIngest, handle)
 }
 
 func (s *Server) Start() error {
 	go func() {
-		log.Printf("Starting server on: localhost%v", s.server.Addr)
-		if err := s.server.ListenAndServe(); err != nil {
+		var err error
+		if s.server.TLSConfig != nil {
+			log.Printf("Starting TLS server on: localhost%v", s.server.Addr)
+			err = s.server.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Starting server on: localhost%v", s.server.Addr)
+			err = s.server.ListenAndServe()
+		}
+		if err != nil {
 			log.Printf("HTTP server error: %v", err)
 		}
 	}()
 
+	if s.grpc != nil {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%s", s.grpcPort))
+		if err != nil {
+			return fmt.Errorf("cannot listen for gRPC: %w", err)
+		}
+		go func() {
+			log.Printf("Starting gRPC server on: localhost:%v", s.grpcPort)
+			if err := s.grpc.Serve(listener); err != nil {
+				log.Printf("gRPC server error: %v", err)
+			}
+		}()
+	}
+
 	//start App
 	s.app.Start()
 	return nil
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
+	// tail streams never end on their own
+	s.app.endStreams()
+
+	if s.grpc != nil {
+		stopped := make(chan struct{})
+		go func() {
+			s.grpc.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.grpc.Stop()
+		}
+	}
+
 	// shutdown App
 	s.app.Shutdown()
 	return s.server.Shutdown(ctx)
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+const (
+	tailBuffer = 256
+	// tailDocID is the ID each published log is matched under.
+	tailDocID = "tail"
+)
+
+// tailHub fans newly indexed logs out to live tail subscribers. Subscribers
+// filter with the query string syntax of search: each log is indexed alone
+// in an in-memory index with the mapping of the real indexes, and every
+// subscriber's query is run against it.
+type tailHub struct {
+	mu   sync.RWMutex
+	subs map[*tailSub]struct{}
+
+	// matchMu serializes the use of index, which holds one log at a time.
+	matchMu sync.Mutex
+	index   bleve.Index
+}
+
+// indexedLog is a log with the ID it is indexed under.
+type indexedLog struct {
+	id   string
+	logs types.LogFormat
+}
+
+// tailSub receives the logs of one tenant matching its query, nil matching
+// every log. Logs are dropped, and counted, when the subscriber falls behind.
+type tailSub struct {
+	tenant string
+	query  query.Query
+	logs   chan indexedLog
+
+	mu      sync.Mutex
+	dropped int
+}
+
+func newTailHub(indexMapping mapping.IndexMapping) (*tailHub, error) {
+	index, err := bleve.NewMemOnly(indexMapping)
+	if err != nil {
+		return nil, err
+	}
+	return &tailHub{subs: map[*tailSub]struct{}{}, index: index}, nil
+}
+
+// parseTailQuery parses a tail query with the query string syntax of search.
+// An empty query matches every log.
+func parseTailQuery(queryString string) (query.Query, error) {
+	if queryString == "" {
+		return nil, nil
+	}
+	q, err := bleve.NewQueryStringQuery(queryString).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	return q, nil
+}
+
+func (h *tailHub) subscribe(tenant string, q query.Query) *tailSub {
+	sub := &tailSub{
+		tenant: tenant,
+		query:  q,
+		logs:   make(chan indexedLog, tailBuffer),
+	}
+
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *tailHub) unsubscribe(sub *tailSub) {
+	h.mu.Lock()
+	delete(h.subs, sub)
+	h.mu.Unlock()
+}
+
+// publish never blocks the log processor on a slow subscriber.
+func (h *tailHub) publish(indexed indexedLog) {
+	h.mu.RLock()
+	var subs []*tailSub
+	for sub := range h.subs {
+		if sub.tenant == indexed.logs.Tenant {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	for _, sub := range h.matching(indexed.logs, subs) {
+		select {
+		case sub.logs <- indexed:
+		default:
+			sub.mu.Lock()
+			sub.dropped++
+			sub.mu.Unlock()
+		}
+	}
+}
+
+// matching returns the subscribers whose query matches logs.
+func (h *tailHub) matching(logs types.LogFormat, subs []*tailSub) []*tailSub {
+	h.matchMu.Lock()
+	defer h.matchMu.Unlock()
+
+	if err := h.index.Index(tailDocID, logs); err != nil {
+		log.Printf("Cannot match log against tails. Error: %v", err)
+		return nil
+	}
+	defer h.index.Delete(tailDocID)
+
+	var matched []*tailSub
+	for _, sub := range subs {
+		if sub.query == nil {
+			matched = append(matched, sub)
+			continue
+		}
+		request := bleve.NewSearchRequestOptions(sub.query, 1, 0, false)
+		result, err := h.index.Search(request)
+		if err == nil && result.Total > 0 {
+			matched = append(matched, sub)
+		}
+	}
+	return matched
+}
+
+func (h *tailHub) close() error {
+	return h.index.Close()
+}
+
+// takeDropped returns and resets the number of dropped logs.
+func (s *tailSub) takeDropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dropped := s.dropped
+	s.dropped = 0
+	return dropped
+}
@@ -0,0 +1,235 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/gorilla/websocket"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	maxTailBackfill = 1000
+	tailKeepAlive   = 15 * time.Second
+)
+
+// tailStream writes tail events to an SSE or WebSocket client.
+type tailStream interface {
+	send(event string, v interface{}) error
+	keepAlive() error
+	// goingAway tells the client that the server shuts down.
+	goingAway() error
+	done() <-chan struct{}
+}
+
+// tailLogs streams newly indexed logs matching ?query= to the client, after
+// the last ?backfill= matches already indexed. Clients asking for a
+// WebSocket upgrade get one JSON message per log; everyone else gets
+// Server-Sent Events.
+func (app App) tailLogs(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tenant, err := requestTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), tenantStatus(err))
+		return
+	}
+	query := r.URL.Query().Get("query")
+	backfill := 0
+	if value := r.URL.Query().Get("backfill"); value != "" {
+		backfill, err = strconv.Atoi(value)
+		if err != nil || backfill < 0 {
+			http.Error(w, "Invalid backfill", http.StatusBadRequest)
+			return
+		}
+		backfill = min(backfill, maxTailBackfill)
+	}
+
+	q, err := parseTailQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// subscribe before backfilling so nothing indexed in between is missed
+	sub := app.tail.subscribe(tenant, q)
+	defer app.tail.unsubscribe(sub)
+
+	var history []indexedLog
+	if backfill > 0 {
+		history, err = app.tailBackfill(r.Context(), tenant, query, backfill)
+		if err != nil {
+			log.Printf("Cannot backfill tail with Query: %v, Error: %v", query, err)
+			http.Error(w, err.Error(), searchStatus(err))
+			return
+		}
+	}
+
+	var stream tailStream
+	if isWebSocketRequest(r) {
+		ws, err := upgradeWebSocket(w, r, app.allowedOrigins)
+		if err != nil {
+			log.Printf("Cannot upgrade tail to WebSocket. Error: %v", err)
+			return
+		}
+		defer ws.Close(websocket.CloseNormalClosure, "")
+		stream = websocketTail{ws}
+	} else {
+		sse, err := newSSETail(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		stream = sse
+	}
+
+	seen := map[string]bool{}
+	for _, indexed := range history {
+		seen[indexed.id] = true
+		if err := stream.send("log", indexed.logs); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(tailKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stream.done():
+			return
+		case <-app.done:
+			stream.goingAway()
+			return
+		case indexed := <-sub.logs:
+			if seen[indexed.id] {
+				continue
+			}
+			if dropped := sub.takeDropped(); dropped > 0 {
+				if err := stream.send("dropped", map[string]int{"dropped": dropped}); err != nil {
+					return
+				}
+			}
+			if err := stream.send("log", indexed.logs); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := stream.keepAlive(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// tailBackfill returns the last n logs matching query, oldest first.
+func (app App) tailBackfill(ctx context.Context, tenant, queryString string, n int) ([]indexedLog, error) {
+	q, err := searchQuery(types.SearchFormat{Query: queryString})
+	if err != nil {
+		return nil, err
+	}
+	ilm, ok := app.tenants.Lookup(tenant)
+	if !ok {
+		return nil, nil
+	}
+
+	searchRequest := bleve.NewSearchRequest(q)
+	searchRequest.Size = n
+	searchRequest.Fields = []string{"*"}
+	searchRequest.SortBy([]string{"-timestamp"})
+
+	searchCtx, done, err := app.searches.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	result, err := ilm.indexSearch.SearchInContext(searchCtx, searchRequest)
+	if err != nil {
+		return nil, app.searches.searchError(ctx, searchCtx, err)
+	}
+	response := newSearchResponse(result)
+
+	history := make([]indexedLog, len(response.Hits))
+	for i, hit := range response.Hits {
+		history[len(history)-1-i] = indexedLog{id: hit.ID, logs: hit.Log()}
+	}
+	return history, nil
+}
+
+type sseTail struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	ctx     <-chan struct{}
+}
+
+func newSSETail(w http.ResponseWriter, r *http.Request) (*sseTail, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming not supported")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseTail{w: w, flusher: flusher, ctx: r.Context().Done()}, nil
+}
+
+func (s *sseTail) send(event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseTail) keepAlive() error {
+	if _, err := fmt.Fprint(s.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// goingAway sends a "shutdown" event; EventSource clients reconnect on
+// their own.
+func (s *sseTail) goingAway() error {
+	return s.send("shutdown", struct{}{})
+}
+
+func (s *sseTail) done() <-chan struct{} {
+	return s.ctx
+}
+
+// websocketTail sends {"event": ..., "data": ...} text messages.
+type websocketTail struct {
+	ws *websocketConn
+}
+
+func (t websocketTail) send(event string, v interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{"event": event, "data": v})
+	if err != nil {
+		return err
+	}
+	return t.ws.WriteText(data)
+}
+
+func (t websocketTail) keepAlive() error {
+	return t.ws.Ping()
+}
+
+func (t websocketTail) goingAway() error {
+	return t.ws.Close(websocket.CloseGoingAway, "server shutting down")
+}
+
+func (t websocketTail) done() <-chan struct{} {
+	return t.ws.Done()
+}
@@ -0,0 +1,263 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/gorilla/websocket"
+)
+
+func TestTailMatching(t *testing.T) {
+	hub, err := newTailHub(bleve.NewIndexMapping())
+	if err != nil {
+		t.Fatalf("newTailHub: %v", err)
+	}
+	defer hub.close()
+
+	logs := types.LogFormat{
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:     "error",
+		Message:   "payment failed for order",
+		Fields:    map[string]interface{}{"service": "billing", "latency_ms": 812},
+		Tenant:    "team-a",
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		query  string
+		want   bool
+	}{
+		{"empty query", "team-a", "", true},
+		{"field", "team-a", "level:error", true},
+		{"other field value", "team-a", "level:info", false},
+		{"bare term", "team-a", "payment", true},
+		{"phrase", "team-a", `"payment failed"`, true},
+		{"phrase out of order", "team-a", `"failed payment"`, false},
+		{"must and must not", "team-a", "+level:error -fields.service:search", true},
+		{"must not", "team-a", "+level:error -fields.service:billing", false},
+		{"numeric range", "team-a", "fields.latency_ms:>500", true},
+		{"numeric range miss", "team-a", "fields.latency_ms:<500", false},
+		{"other tenant", "team-b", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q, err := parseTailQuery(test.query)
+			if err != nil {
+				t.Fatalf("parseTailQuery(%q): %v", test.query, err)
+			}
+			sub := hub.subscribe(test.tenant, q)
+			defer hub.unsubscribe(sub)
+
+			hub.publish(indexedLog{id: "1", logs: logs})
+			select {
+			case <-sub.logs:
+				if !test.want {
+					t.Errorf("query %q matched", test.query)
+				}
+			default:
+				if test.want {
+					t.Errorf("query %q did not match", test.query)
+				}
+			}
+		})
+	}
+}
+
+func TestTailInvalidQuery(t *testing.T) {
+	a, _ := newTestApp(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/log/tail?query=level:%3E", nil)
+	a.tailLogs(w, r, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if n := tailSubscribers(a); n != 0 {
+		t.Errorf("%d subscribers left", n)
+	}
+}
+
+func TestTailSSE(t *testing.T) {
+	a, indexed := newTestApp(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.tailLogs(w, r, nil)
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?query=level:error")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	waitSubscribed(t, a)
+
+	a.Ingest("http", types.LogFormat{Level: "info", Message: "filtered out"})
+	a.Ingest("http", types.LogFormat{Level: "error", Message: "disk full"})
+	waitIndexed(t, indexed, 2)
+	a.endStreams()
+
+	// the stream ends after the shutdown event
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	events := strings.Split(strings.TrimSpace(string(body)), "\n\n")
+	if len(events) != 2 || !strings.Contains(events[0], "disk full") || !strings.HasPrefix(events[1], "event: shutdown") {
+		t.Errorf("events = %q, want the error log and a shutdown event", events)
+	}
+}
+
+func TestTailWebSocket(t *testing.T) {
+	a, indexed := newTestApp(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.tailLogs(w, r, nil)
+	}))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?query=level:error"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	waitSubscribed(t, a)
+
+	a.Ingest("http", types.LogFormat{Level: "error", Message: "disk full"})
+	waitIndexed(t, indexed, 1)
+	var message struct {
+		Event string          `json:"event"`
+		Data  types.LogFormat `json:"data"`
+	}
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	if message.Event != "log" || message.Data.Message != "disk full" {
+		t.Errorf("message = %+v", message)
+	}
+
+	a.endStreams()
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("read after shutdown = %v, want close %d", err, websocket.CloseGoingAway)
+	}
+}
+
+func TestTailWebSocketOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"no origin", nil, "", true},
+		{"same host", nil, "http://{host}", true},
+		{"other origin", nil, "https://evil.example", false},
+		{"allowed origin", []string{"https://app.example"}, "https://app.example", true},
+		{"not listed", []string{"https://app.example"}, "https://evil.example", false},
+		{"any origin", []string{"*"}, "https://evil.example", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ws, err := upgradeWebSocket(w, r, test.allowed)
+				if err == nil {
+					ws.Close(websocket.CloseNormalClosure, "")
+				}
+			}))
+			defer server.Close()
+
+			header := http.Header{}
+			if test.origin != "" {
+				header.Set("Origin", strings.ReplaceAll(test.origin, "{host}", strings.TrimPrefix(server.URL, "http://")))
+			}
+			url := "ws" + strings.TrimPrefix(server.URL, "http")
+			conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+			if err == nil {
+				conn.Close()
+			}
+			if got := err == nil; got != test.want {
+				t.Fatalf("upgraded = %v, want %v (err %v)", got, test.want, err)
+			}
+			if !test.want && resp.StatusCode != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+func TestWebSocketRejectsUnmaskedFrames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r, nil)
+		if err != nil {
+			return
+		}
+		<-ws.Done()
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	handshake := "GET / HTTP/1.1\r\nHost: " + conn.RemoteAddr().String() +
+		"\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\n\r\n"
+	if _, err := io.WriteString(conn, handshake); err != nil {
+		t.Fatalf("write handshake: %v", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+
+	// an unmasked text frame "hi"
+	if _, err := conn.Write([]byte{0x81, 0x02, 'h', 'i'}); err != nil {
+		t.Fatalf("write frame: %v", err)
+	}
+	// the server answers with a protocol error close frame and hangs up
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.HasPrefix(rest, []byte{0x88}) || len(rest) < 4 || int(rest[2])<<8|int(rest[3]) != websocket.CloseProtocolError {
+		t.Errorf("server sent % x, want a close frame with code %d", rest, websocket.CloseProtocolError)
+	}
+}
+
+// waitSubscribed waits for a tail to subscribe.
+func waitSubscribed(t *testing.T, a *App) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for tailSubscribers(a) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("tail did not subscribe")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func tailSubscribers(a *App) int {
+	a.tail.mu.RLock()
+	defer a.tail.mu.RUnlock()
+	return len(a.tail.subs)
+}
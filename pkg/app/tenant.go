@@ -0,0 +1,211 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/auth"
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+const tenantHeader = "X-Tenant-ID"
+
+var validTenant = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,62}$`)
+
+var errTenantForbidden = errors.New("tenant not allowed")
+
+// TenantManager keeps one IndexLifecycleManager, and so one set of hourly
+// indexes and one search alias, per tenant. The empty tenant uses the
+// configured base index name.
+type TenantManager struct {
+	mu               sync.RWMutex
+	baseIndexName    string
+	defaultRetention time.Duration
+	tenants          map[string]config.TenantConfig
+	managers         map[string]*IndexLifecycleManager
+	indexMapping     mapping.IndexMapping
+	started          bool
+}
+
+func NewTenantManager(baseIndexName string, retention time.Duration, tenants map[string]config.TenantConfig, indexCfg config.IndexConfig) (*TenantManager, error) {
+	tm := &TenantManager{
+		baseIndexName:    baseIndexName,
+		defaultRetention: retention,
+		tenants:          tenants,
+		managers:         map[string]*IndexLifecycleManager{},
+		indexMapping:     newIndexMapping(indexCfg),
+	}
+
+	if _, err := tm.Get(""); err != nil {
+		return nil, err
+	}
+
+	// open existing tenants so their indexes are searchable and cleaned up
+	for _, tenant := range tm.findTenants() {
+		if _, err := tm.Get(tenant); err != nil {
+			log.Printf("Cannot open indexes of tenant %v. Error: %v", tenant, err)
+		}
+	}
+
+	return tm, nil
+}
+
+func (tm *TenantManager) tenantsDir() string {
+	return filepath.Join(filepath.Dir(tm.baseIndexName), "tenants")
+}
+
+func (tm *TenantManager) indexName(tenant string) string {
+	if tenant == "" {
+		return tm.baseIndexName
+	}
+	if prefix := tm.tenants[tenant].IndexPrefix; prefix != "" {
+		return prefix
+	}
+	return filepath.Join(tm.tenantsDir(), tenant, filepath.Base(tm.baseIndexName))
+}
+
+func (tm *TenantManager) retention(tenant string) time.Duration {
+	if retention := time.Duration(tm.tenants[tenant].Retention); retention > 0 {
+		return retention
+	}
+	return tm.defaultRetention
+}
+
+func (tm *TenantManager) findTenants() []string {
+	tenants := map[string]bool{}
+	for tenant := range tm.tenants {
+		tenants[tenant] = true
+	}
+	if entries, err := os.ReadDir(tm.tenantsDir()); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() && validTenant.MatchString(entry.Name()) {
+				tenants[entry.Name()] = true
+			}
+		}
+	}
+
+	var list []string
+	for tenant := range tenants {
+		list = append(list, tenant)
+	}
+	return list
+}
+
+// Lookup returns the manager of an existing tenant without creating one.
+func (tm *TenantManager) Lookup(tenant string) (*IndexLifecycleManager, bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	ilm, ok := tm.managers[tenant]
+	return ilm, ok
+}
+
+// Get returns the manager of tenant, creating its indexes on first use.
+func (tm *TenantManager) Get(tenant string) (*IndexLifecycleManager, error) {
+	if ilm, ok := tm.Lookup(tenant); ok {
+		return ilm, nil
+	}
+	if tenant != "" && !validTenant.MatchString(tenant) {
+		return nil, fmt.Errorf("invalid tenant %q", tenant)
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if ilm, ok := tm.managers[tenant]; ok {
+		return ilm, nil
+	}
+
+	ilm, err := NewIndexLifecycleManager(tm.indexName(tenant), tm.retention(tenant), tm.indexMapping)
+	if err != nil {
+		return nil, err
+	}
+	if tm.started {
+		if err := ilm.StartScheduler(); err != nil {
+			log.Printf("Error starting ILM Scheduler of tenant %v. Error: %v", tenant, err)
+		}
+	}
+	tm.managers[tenant] = ilm
+	log.Printf("Opened indexes of tenant %q", tenant)
+
+	return ilm, nil
+}
+
+func (tm *TenantManager) StartScheduler() error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.started = true
+	for tenant, ilm := range tm.managers {
+		if err := ilm.StartScheduler(); err != nil {
+			return fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+func (tm *TenantManager) StopScheduler() {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.started = false
+	for _, ilm := range tm.managers {
+		ilm.StopScheduler()
+	}
+}
+
+// Close closes the indexes of every tenant.
+func (tm *TenantManager) Close() error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	var firstErr error
+	for tenant, ilm := range tm.managers {
+		if err := ilm.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+	}
+	tm.managers = map[string]*IndexLifecycleManager{}
+	return firstErr
+}
+
+// requestTenant returns the tenant of an HTTP request. An API key bound to a
+// tenant always acts as that tenant and other keys as the default tenant,
+// except admin keys, which may pick any tenant with the X-Tenant-ID header.
+// Without API keys the header is used. Requests without either belong to
+// the default tenant.
+func requestTenant(r *http.Request) (string, error) {
+	return resolveTenant(r.Context(), r.Header.Get(tenantHeader))
+}
+
+// resolveTenant picks the tenant of the identity in ctx, or else the tenant
+// the caller asked for.
+func resolveTenant(ctx context.Context, tenant string) (string, error) {
+	if id, ok := auth.FromContext(ctx); ok && (id.Tenant != "" || id.Role != auth.RoleAdmin) {
+		if tenant != "" && tenant != id.Tenant {
+			return "", fmt.Errorf("%w: API key %q may not act as tenant %q", errTenantForbidden, id.Name, tenant)
+		}
+		return id.Tenant, nil
+	}
+	if tenant != "" && !validTenant.MatchString(tenant) {
+		return "", fmt.Errorf("invalid tenant %q", tenant)
+	}
+	return tenant, nil
+}
+
+// tenantStatus is the HTTP status for an error from requestTenant.
+func tenantStatus(err error) int {
+	if errors.Is(err, errTenantForbidden) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
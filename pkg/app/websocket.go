@@ -0,0 +1,109 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// websocketWriteWait bounds every write, so a client that stops reading
+	// cannot hold a tail open.
+	websocketWriteWait  = 10 * time.Second
+	maxWebSocketMessage = 1 << 20
+)
+
+// websocketConn pushes text messages to a client. Client messages are read
+// only to answer control frames and notice when the client goes away.
+type websocketConn struct {
+	conn *websocket.Conn
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+func isWebSocketRequest(r *http.Request) bool {
+	return websocket.IsWebSocketUpgrade(r)
+}
+
+// upgradeWebSocket completes the opening handshake for requests from an
+// allowed origin. On failure an HTTP error has already been written.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, allowedOrigins []string) (*websocketConn, error) {
+	upgrader := websocket.Upgrader{
+		HandshakeTimeout: websocketWriteWait,
+		CheckOrigin:      originChecker(allowedOrigins),
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadLimit(maxWebSocketMessage)
+
+	ws := &websocketConn{conn: conn, closed: make(chan struct{})}
+	go ws.readLoop()
+	return ws, nil
+}
+
+// originChecker accepts requests from the server's own host and from the
+// allowed origins, "*" allowing every origin. Requests without an Origin
+// header do not come from a browser and are accepted too.
+func originChecker(allowedOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+// Done is closed once the client closed the connection or it failed.
+func (ws *websocketConn) Done() <-chan struct{} {
+	return ws.closed
+}
+
+func (ws *websocketConn) WriteText(payload []byte) error {
+	ws.conn.SetWriteDeadline(time.Now().Add(websocketWriteWait))
+	return ws.conn.WriteMessage(websocket.TextMessage, payload)
+}
+
+func (ws *websocketConn) Ping() error {
+	return ws.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketWriteWait))
+}
+
+// Close sends a close frame with code and closes the connection.
+func (ws *websocketConn) Close(code int, reason string) error {
+	message := websocket.FormatCloseMessage(code, reason)
+	ws.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(websocketWriteWait))
+	ws.shutdown()
+	return nil
+}
+
+func (ws *websocketConn) shutdown() {
+	ws.once.Do(func() {
+		close(ws.closed)
+		ws.conn.Close()
+	})
+}
+
+// readLoop discards client messages. The connection answers pings and close
+// frames itself and rejects unmasked or oversized frames.
+func (ws *websocketConn) readLoop() {
+	defer ws.shutdown()
+
+	for {
+		if _, _, err := ws.conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// CreateKey is the `keys create` command. It prints the new token once.
+func CreateKey(cmd *cobra.Command, args []string) {
+	file, _ := cmd.Flags().GetString("file")
+	name, _ := cmd.Flags().GetString("name")
+	role, _ := cmd.Flags().GetString("role")
+	tenant, _ := cmd.Flags().GetString("tenant")
+
+	store, err := LoadStore(file)
+	if err != nil {
+		log.Fatalf("Cannot load key file: %v", err)
+	}
+	token, err := store.Create(name, Role(role), tenant)
+	if err != nil {
+		log.Fatalf("Cannot create key: %v", err)
+	}
+
+	fmt.Println(token)
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleIngest Role = "ingest"
+	RoleRead   Role = "read"
+	RoleAdmin  Role = "admin"
+)
+
+// reloadInterval bounds how often the key file is checked for changes.
+const reloadInterval = 10 * time.Second
+
+// Key is a stored API key. Only the SHA-256 hash of the token is kept.
+type Key struct {
+	Name   string `json:"name"`
+	Hash   string `json:"hash"`
+	Role   Role   `json:"role"`
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	Name   string
+	Role   Role
+	Tenant string
+}
+
+// Allows reports whether the identity may act with role. Admin may do
+// everything.
+func (id Identity) Allows(role Role) bool {
+	return id.Role == RoleAdmin || id.Role == role
+}
+
+type keyFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// Store holds the API keys of a JSON key file and picks up changes to it.
+type Store struct {
+	path string
+
+	mu        sync.RWMutex
+	keys      []Key
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func LoadStore(path string) (*Store, error) {
+	s := &Store{path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) load() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.keys = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("cannot parse key file %v: %w", s.path, err)
+	}
+	for _, key := range file.Keys {
+		if !validRole(key.Role) {
+			return fmt.Errorf("key %q has unknown role %q", key.Name, key.Role)
+		}
+	}
+
+	s.keys = file.Keys
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// reloadIfChanged re-reads the key file when its modification time changed.
+func (s *Store) reloadIfChanged() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.lastCheck) < reloadInterval {
+		return
+	}
+	s.lastCheck = time.Now()
+
+	info, err := os.Stat(s.path)
+	if err != nil || info.ModTime().Equal(s.modTime) {
+		return
+	}
+	if err := s.load(); err != nil {
+		log.Printf("Cannot reload key file, keeping previous keys. Error: %v", err)
+		return
+	}
+	log.Printf("Reloaded %d API keys", len(s.keys))
+}
+
+// Authenticate returns the identity owning token.
+func (s *Store) Authenticate(token string) (Identity, bool) {
+	s.reloadIfChanged()
+
+	hash := HashToken(token)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) == 1 {
+			return Identity{Name: key.Name, Role: key.Role, Tenant: key.Tenant}, true
+		}
+	}
+	return Identity{}, false
+}
+
+// Create generates a new token, stores its hash and returns the token, which
+// cannot be recovered later.
+func (s *Store) Create(name string, role Role, tenant string) (string, error) {
+	if name == "" {
+		return "", errors.New("key name is required")
+	}
+	if !validRole(role) {
+		return "", fmt.Errorf("unknown role %q", role)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.keys {
+		if key.Name == name {
+			return "", fmt.Errorf("key %q already exists", name)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := "glk_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	keys := append(s.keys, Key{Name: name, Hash: HashToken(token), Role: role, Tenant: tenant})
+	if err := s.save(keys); err != nil {
+		return "", err
+	}
+	s.keys = keys
+	return token, nil
+}
+
+func (s *Store) save(keys []Key) error {
+	data, err := json.MarshalIndent(keyFile{Keys: keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func validRole(role Role) bool {
+	return role == RoleIngest || role == RoleRead || role == RoleAdmin
+}
@@ -0,0 +1,328 @@
+// Package client sends logs to a go-logger server or proxy and searches them.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/tlsconfig"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+const (
+	defaultBatchSize     = 500
+	defaultBufferSize    = 10000
+	defaultFlushInterval = time.Second
+	defaultMaxRetries    = 5
+	defaultMaxBackoff    = 30 * time.Second
+	defaultTimeout       = 30 * time.Second
+)
+
+var (
+	// ErrBufferFull is returned by Log when the buffer is full and the drop
+	// policy discarded the log.
+	ErrBufferFull = errors.New("client buffer full")
+	ErrClosed     = errors.New("client closed")
+	// ErrUnencodable is passed to OnError with logs that cannot be encoded as
+	// JSON, even after turning their bad fields into strings.
+	ErrUnencodable = errors.New("log cannot be encoded as JSON")
+)
+
+// DropPolicy decides what Log does when the buffer is full.
+type DropPolicy int
+
+const (
+	// DropNewest discards the log being added.
+	DropNewest DropPolicy = iota
+	// DropOldest discards the oldest buffered log to make room.
+	DropOldest
+	// Block waits until a flush makes room.
+	Block
+)
+
+type Config struct {
+	// URL of the server or proxy, e.g. https://logs.example.com:8080.
+	URL    string
+	APIKey string
+	// Tenant is sent as X-Tenant-ID, for keys not bound to a tenant.
+	Tenant string
+	// Source selects the server-side pipeline (?source=).
+	Source string
+	TLS    tlsconfig.ClientOptions
+	// HTTPClient overrides the client built from TLS.
+	HTTPClient *http.Client
+
+	BatchSize     int
+	BufferSize    int
+	FlushInterval time.Duration
+	DropPolicy    DropPolicy
+	// MaxRetries bounds the attempts for one batch before it is dropped.
+	MaxRetries  int
+	MaxBackoff  time.Duration
+	DisableGzip bool
+
+	// OnError is called when a batch is dropped after failing. It defaults
+	// to logging the error.
+	OnError func(err error, logs []types.LogFormat)
+}
+
+// Stats counts logs by outcome since the client was created. Rejected logs
+// reached the server but could not be parsed there.
+type Stats struct {
+	Sent     int64 `json:"sent"`
+	Rejected int64 `json:"rejected"`
+	Dropped  int64 `json:"dropped"`
+	Failed   int64 `json:"failed"`
+}
+
+type flushRequest struct {
+	ctx  context.Context
+	done chan error
+}
+
+// Client buffers logs in memory and sends them in batches from a background
+// goroutine. It is safe for concurrent use.
+type Client struct {
+	cfg     Config
+	baseURL string
+	http    *http.Client
+
+	mu     sync.Mutex
+	space  *sync.Cond
+	buffer []types.LogFormat
+	closed bool
+	stats  Stats
+
+	notify   chan struct{}
+	flushReq chan flushRequest
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+}
+
+func New(cfg Config) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("client URL is required")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultBufferSize
+	}
+	if cfg.BufferSize < cfg.BatchSize {
+		cfg.BufferSize = cfg.BatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(err error, logs []types.LogFormat) {
+			log.Printf("Dropped %d logs. Error: %v", len(logs), err)
+		}
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		tlsConfig, err := tlsconfig.Client(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		httpClient = &http.Client{Timeout: defaultTimeout, Transport: transport}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		cfg:      cfg,
+		baseURL:  strings.TrimRight(cfg.URL, "/"),
+		http:     httpClient,
+		notify:   make(chan struct{}, 1),
+		flushReq: make(chan flushRequest),
+		ctx:      ctx,
+		cancel:   cancel,
+		stopped:  make(chan struct{}),
+	}
+	c.space = sync.NewCond(&c.mu)
+
+	go c.run()
+	return c, nil
+}
+
+// Log buffers logs for sending. It does not block unless the drop policy is
+// Block and the buffer is full.
+func (c *Client) Log(logs types.LogFormat) error {
+	if logs.Timestamp.IsZero() {
+		logs.Timestamp = time.Now()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	for len(c.buffer) >= c.cfg.BufferSize {
+		switch c.cfg.DropPolicy {
+		case DropOldest:
+			c.buffer = c.buffer[1:]
+			c.stats.Dropped++
+		case Block:
+			c.space.Wait()
+			if c.closed {
+				return ErrClosed
+			}
+		default:
+			c.stats.Dropped++
+			return ErrBufferFull
+		}
+	}
+
+	c.buffer = append(c.buffer, logs)
+	if len(c.buffer) >= c.cfg.BatchSize {
+		select {
+		case c.notify <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush sends every log buffered so far and waits until that is done or ctx
+// expires.
+func (c *Client) Flush(ctx context.Context) error {
+	req := flushRequest{ctx: ctx, done: make(chan error, 1)}
+	select {
+	case c.flushReq <- req:
+	case <-c.stopped:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting logs, flushes the buffer and stops the background
+// goroutine. Logs still buffered when ctx expires are dropped.
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.closed = true
+	c.space.Broadcast()
+	c.mu.Unlock()
+
+	err := c.Flush(ctx)
+	c.cancel()
+	<-c.stopped
+
+	c.mu.Lock()
+	c.stats.Dropped += int64(len(c.buffer))
+	c.buffer = nil
+	c.mu.Unlock()
+	return err
+}
+
+// Stats returns the client's counters.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *Client) run() {
+	defer close(c.stopped)
+
+	ticker := time.NewTicker(c.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.flush(c.ctx, 1)
+		case <-c.notify:
+			c.flush(c.ctx, c.cfg.BatchSize)
+		case req := <-c.flushReq:
+			req.done <- c.flush(req.ctx, 1)
+		}
+	}
+}
+
+// flush sends batches while at least atLeast logs are buffered and returns
+// the last send error.
+func (c *Client) flush(ctx context.Context, atLeast int) error {
+	var lastErr error
+	for {
+		batch := c.take(atLeast)
+		if batch == nil {
+			return lastErr
+		}
+		lines, sent, bad := marshalLogs(batch)
+		if len(bad) > 0 {
+			c.mu.Lock()
+			c.stats.Failed += int64(len(bad))
+			c.mu.Unlock()
+			c.cfg.OnError(ErrUnencodable, bad)
+		}
+		if len(sent) == 0 {
+			continue
+		}
+
+		result, err := c.sendBatch(ctx, lines)
+		if err != nil {
+			lastErr = err
+			c.mu.Lock()
+			c.stats.Failed += int64(len(sent))
+			c.mu.Unlock()
+			c.cfg.OnError(err, sent)
+			if ctx.Err() != nil {
+				return err
+			}
+			continue
+		}
+		c.mu.Lock()
+		c.stats.Sent += int64(result.Accepted)
+		c.stats.Rejected += int64(result.Failed)
+		c.mu.Unlock()
+	}
+}
+
+// take removes up to a batch of logs from the buffer when at least atLeast
+// are buffered.
+func (c *Client) take(atLeast int) []types.LogFormat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.buffer) == 0 || len(c.buffer) < atLeast {
+		return nil
+	}
+	n := min(len(c.buffer), c.cfg.BatchSize)
+	batch := make([]types.LogFormat, n)
+	copy(batch, c.buffer)
+	c.buffer = c.buffer[n:]
+	c.space.Broadcast()
+	return batch
+}
@@ -0,0 +1,206 @@
+package client
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// bulkServer decodes the batches posted to it and answers with the statuses
+// in codes, then 200.
+type bulkServer struct {
+	mu       sync.Mutex
+	codes    []int
+	attempts int
+	logs     []map[string]interface{}
+}
+
+func (b *bulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.attempts++
+	if len(b.codes) > 0 {
+		code := b.codes[0]
+		b.codes = b.codes[1:]
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+
+	var result types.BulkResult
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var lg map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &lg); err != nil {
+			result.Failed++
+			continue
+		}
+		b.logs = append(b.logs, lg)
+		result.Accepted++
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func (b *bulkServer) received() []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]map[string]interface{}(nil), b.logs...)
+}
+
+// errorLog records what the client reports to OnError.
+type errorLog struct {
+	mu   sync.Mutex
+	errs []error
+	logs []types.LogFormat
+}
+
+func (e *errorLog) onError(err error, logs []types.LogFormat) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errs = append(e.errs, err)
+	e.logs = append(e.logs, logs...)
+}
+
+func newTestClient(t *testing.T, b *bulkServer, cfg Config) *Client {
+	t.Helper()
+	server := httptest.NewServer(b)
+	t.Cleanup(server.Close)
+
+	cfg.URL = server.URL
+	if cfg.FlushInterval == 0 {
+		cfg.FlushInterval = time.Hour
+	}
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+func closeClient(t *testing.T, c *Client) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestUnencodableLogs(t *testing.T) {
+	for _, gzipped := range []bool{true, false} {
+		b := &bulkServer{}
+		errs := &errorLog{}
+		c := newTestClient(t, b, Config{DisableGzip: !gzipped, OnError: errs.onError})
+
+		logs := []types.LogFormat{
+			{Message: "plain", Fields: map[string]interface{}{"user": "jane"}},
+			{Message: "nan field", Fields: map[string]interface{}{"ratio": math.NaN(), "user": "jane"}},
+			{Message: "channel field", Fields: map[string]interface{}{"ch": make(chan int)}},
+			{Message: "nan sample rate", SampleRate: math.Inf(1)},
+			{Message: "last"},
+		}
+		for _, lg := range logs {
+			if err := c.Log(lg); err != nil {
+				t.Fatalf("Log: %v", err)
+			}
+		}
+		closeClient(t, c)
+
+		received := b.received()
+		if len(received) != 4 {
+			t.Fatalf("gzip %v: server received %d logs, want 4: %v", gzipped, len(received), received)
+		}
+		fields := received[1]["fields"].(map[string]interface{})
+		if fields["ratio"] != "NaN" || fields["user"] != "jane" {
+			t.Errorf("fields = %v, want the NaN as a string and the rest untouched", fields)
+		}
+		if _, ok := received[2]["fields"].(map[string]interface{})["ch"].(string); !ok {
+			t.Errorf("channel field = %v, want a string", received[2]["fields"])
+		}
+
+		if len(errs.logs) != 1 || errs.logs[0].Message != "nan sample rate" || !errors.Is(errs.errs[0], ErrUnencodable) {
+			t.Errorf("OnError got %v %v, want only the log with the infinite sample rate", errs.errs, errs.logs)
+		}
+		if stats := c.Stats(); stats.Sent != 4 || stats.Failed != 1 {
+			t.Errorf("stats = %+v, want 4 sent and 1 failed", stats)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		codes    []int
+		sent     int64
+		failed   int64
+		attempts int
+	}{
+		{"accepted", nil, 2, 0, 1},
+		{"rate limited then accepted", []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}, 2, 0, 3},
+		{"bad request is not retried", []int{http.StatusBadRequest}, 0, 2, 1},
+		{"gives up after max retries", []int{500, 500, 500, 500}, 0, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &bulkServer{codes: tt.codes}
+			errs := &errorLog{}
+			c := newTestClient(t, b, Config{MaxRetries: 3, MaxBackoff: time.Millisecond, OnError: errs.onError})
+
+			c.Log(types.LogFormat{Message: "one"})
+			c.Log(types.LogFormat{Message: "two"})
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := c.Close(ctx); (err != nil) != (tt.failed > 0) {
+				t.Errorf("Close error = %v, want one only when the batch failed", err)
+			}
+
+			if b.attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", b.attempts, tt.attempts)
+			}
+			if stats := c.Stats(); stats.Sent != tt.sent || stats.Failed != tt.failed {
+				t.Errorf("stats = %+v, want %d sent and %d failed", stats, tt.sent, tt.failed)
+			}
+			if int64(len(errs.logs)) != tt.failed {
+				t.Errorf("OnError got %d logs, want %d", len(errs.logs), tt.failed)
+			}
+		})
+	}
+}
+
+func TestBufferFull(t *testing.T) {
+	c := newTestClient(t, &bulkServer{}, Config{BatchSize: 2, BufferSize: 2})
+	// Fill the buffer directly, which does not wake the background flush.
+	c.mu.Lock()
+	c.buffer = append(c.buffer, types.LogFormat{}, types.LogFormat{})
+	c.mu.Unlock()
+
+	if err := c.Log(types.LogFormat{Message: "third"}); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("Log error = %v, want ErrBufferFull", err)
+	}
+	closeClient(t, c)
+	if stats := c.Stats(); stats.Dropped != 1 || stats.Sent != 2 {
+		t.Errorf("stats = %+v, want 1 dropped and 2 sent", stats)
+	}
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+const searchPath = "/api/v1/log/search"
+
+// Search runs query and returns its hits. Responses from the proxy, which
+// concatenates one result per backend, are merged into one.
+func (c *Client) Search(ctx context.Context, query types.SearchFormat) (*types.SearchResponse, error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+searchPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp)
+	}
+	return decodeSearch(resp.Body)
+}
+
+func decodeSearch(body io.Reader) (*types.SearchResponse, error) {
+	var merged types.SearchResponse
+	var parts int
+
+	decoder := json.NewDecoder(body)
+	for {
+		var part types.SearchResponse
+		err := decoder.Decode(&part)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parts++
+
+		merged.Hits = append(merged.Hits, part.Hits...)
+		merged.TotalHits += part.TotalHits
+		merged.MaxScore = max(merged.MaxScore, part.MaxScore)
+		merged.Took = max(merged.Took, part.Took)
+		merged.SkippedIndexes += part.SkippedIndexes
+		merged.Aggregations = mergeAggregations(merged.Aggregations, part.Aggregations)
+	}
+	if parts == 0 {
+		return nil, errors.New("empty search response")
+	}
+
+	if parts > 1 {
+		sort.SliceStable(merged.Hits, func(i, j int) bool {
+			return merged.Hits[i].Score > merged.Hits[j].Score
+		})
+	}
+	return &merged, nil
+}
+
+// mergeAggregations adds the bucket counts of b to a. Terms are re-ranked by
+// count; histogram and range buckets keep their order.
+func mergeAggregations(a, b map[string]types.AggregationResult) map[string]types.AggregationResult {
+	if a == nil {
+		return b
+	}
+	for name, other := range b {
+		result, ok := a[name]
+		if !ok {
+			a[name] = other
+			continue
+		}
+		result.Other += other.Other
+		result.Missing += other.Missing
+
+		index := map[string]int{}
+		for i, bucket := range result.Buckets {
+			index[bucket.Key] = i
+		}
+		for _, bucket := range other.Buckets {
+			i, ok := index[bucket.Key]
+			if !ok {
+				index[bucket.Key] = len(result.Buckets)
+				result.Buckets = append(result.Buckets, bucket)
+				continue
+			}
+			result.Buckets[i].Count += bucket.Count
+			result.Buckets[i].Aggregations = mergeAggregations(result.Buckets[i].Aggregations, bucket.Aggregations)
+		}
+		if result.Type == types.AggregationTerms {
+			sort.SliceStable(result.Buckets, func(i, j int) bool {
+				return result.Buckets[i].Count > result.Buckets[j].Count
+			})
+		}
+		a[name] = result
+	}
+	return a
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+const (
+	bulkPath       = "/api/v1/log/bulk"
+	initialBackoff = 500 * time.Millisecond
+)
+
+// statusError is a non-200 response. Only 429 and 5xx are retried.
+type statusError struct {
+	status     int
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.status, e.message)
+}
+
+func (e *statusError) temporary() bool {
+	return e.status == http.StatusTooManyRequests || e.status >= 500
+}
+
+// sendBatch posts encoded logs to the bulk endpoint as NDJSON, retrying
+// temporary failures with exponential backoff.
+func (c *Client) sendBatch(ctx context.Context, lines [][]byte) (types.BulkResult, error) {
+	body, err := c.encode(lines)
+	if err != nil {
+		return types.BulkResult{}, err
+	}
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		result, err := c.post(ctx, body)
+		if err == nil {
+			return result, nil
+		}
+
+		wait := backoff
+		if se, ok := err.(*statusError); ok {
+			if !se.temporary() {
+				return result, err
+			}
+			if se.retryAfter > 0 {
+				wait = min(se.retryAfter, c.cfg.MaxBackoff)
+			}
+		}
+		if attempt >= c.cfg.MaxRetries {
+			return result, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, c.cfg.MaxBackoff)
+	}
+}
+
+// marshalLogs encodes each log on its own, so one bad log does not fail the
+// batch. Fields that JSON cannot represent, such as NaN or channels, are sent
+// as strings; logs that still cannot be encoded are returned in bad.
+func marshalLogs(logs []types.LogFormat) (lines [][]byte, sent, bad []types.LogFormat) {
+	for _, lg := range logs {
+		line, err := json.Marshal(lg)
+		if err != nil {
+			lg.Fields = stringifyFields(lg.Fields)
+			line, err = json.Marshal(lg)
+		}
+		if err != nil {
+			bad = append(bad, lg)
+			continue
+		}
+		lines = append(lines, line)
+		sent = append(sent, lg)
+	}
+	return lines, sent, bad
+}
+
+// encode joins lines into an NDJSON body, gzipped unless disabled.
+func (c *Client) encode(lines [][]byte) ([]byte, error) {
+	var body bytes.Buffer
+	var w io.Writer = &body
+
+	var gz *gzip.Writer
+	if !c.cfg.DisableGzip {
+		gz = gzip.NewWriter(&body)
+		w = gz
+	}
+
+	for _, line := range lines {
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return nil, err
+		}
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return body.Bytes(), nil
+}
+
+// stringifyFields returns a copy of fields with every value JSON cannot
+// encode replaced by its fmt representation.
+func stringifyFields(fields map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		if _, err := json.Marshal(value); err != nil {
+			value = fmt.Sprint(value)
+		}
+		out[key] = value
+	}
+	return out
+}
+
+func (c *Client) post(ctx context.Context, body []byte) (types.BulkResult, error) {
+	var result types.BulkResult
+
+	target := c.baseURL + bulkPath
+	if c.cfg.Source != "" {
+		target += "?source=" + url.QueryEscape(c.cfg.Source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if !c.cfg.DisableGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	c.setHeaders(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return result, newStatusError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("cannot decode bulk response: %w", err)
+	}
+	return result, nil
+}
+
+func (c *Client) setHeaders(req *http.Request) {
+	if c.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+	if c.cfg.Tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.cfg.Tenant)
+	}
+}
+
+func newStatusError(resp *http.Response) *statusError {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err := &statusError{status: resp.StatusCode, message: string(bytes.TrimSpace(msg))}
+	if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+		err.retryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
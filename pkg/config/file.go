@@ -0,0 +1,222 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// File is the optional JSON configuration passed with --config.
+type File struct {
+	Pipelines map[string][]ProcessorConfig `json:"pipelines"`
+	Sources   map[string]SourceConfig      `json:"sources"`
+	Redaction RedactionConfig              `json:"redaction"`
+	Shipper   ShipperConfig                `json:"shipper"`
+	Tenants   map[string]TenantConfig      `json:"tenants"`
+	RateLimit RateLimitConfig              `json:"rate_limits"`
+	Sampling  SamplingConfig               `json:"sampling"`
+	Tail      TailConfig                   `json:"tail"`
+	Search    SearchConfig                 `json:"search"`
+	Index     IndexConfig                  `json:"index"`
+}
+
+// TailConfig lists the origins, besides the server's own, whose web pages
+// may open a live tail WebSocket, e.g. "https://dashboard.example.com".
+type TailConfig struct {
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+}
+
+// IndexConfig tunes how logs are indexed. It applies to indexes created
+// after a change; older indexes keep their mapping until retention removes
+// them.
+type IndexConfig struct {
+	// KeywordFields are structured fields indexed as whole values instead of
+	// analyzed text, so term queries and terms aggregations match the exact
+	// value, e.g. "checkout-api" rather than "checkout" and "api". While
+	// older indexes remain, results on these fields mix both.
+	KeywordFields []string `json:"keyword_fields,omitempty"`
+}
+
+// SearchConfig bounds the cost of searches. Zero values use the defaults.
+type SearchConfig struct {
+	// Timeout is the longest a search may run, 30s by default.
+	Timeout Duration `json:"timeout,omitempty"`
+	// MaxResultWindow caps from + size of a search, 10000 by default.
+	// Larger result sets can be exported.
+	MaxResultWindow int `json:"max_result_window,omitempty"`
+	// MaxConcurrent is how many searches may run at once, 16 by default.
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+	// ExportTimeout is the longest an export or a streamed gRPC search may
+	// run, 10m by default.
+	ExportTimeout Duration `json:"export_timeout,omitempty"`
+	// MaxConcurrentExports is how many exports and streamed searches may run
+	// at once, 4 by default. They do not take search slots.
+	MaxConcurrentExports int `json:"max_concurrent_exports,omitempty"`
+}
+
+// SamplingConfig keeps a fraction of logs per level before they are
+// queued. Services overrides the level rates of individual services,
+// identified by ServiceField. Logs sharing the same KeyField value are kept
+// or dropped together.
+type SamplingConfig struct {
+	Levels       map[string]float64         `json:"levels,omitempty"`
+	DefaultRate  *float64                   `json:"default_rate,omitempty"`
+	KeyField     string                     `json:"key_field,omitempty"`
+	ServiceField string                     `json:"service_field,omitempty"`
+	Services     map[string]ServiceSampling `json:"services,omitempty"`
+}
+
+type ServiceSampling struct {
+	Levels      map[string]float64 `json:"levels,omitempty"`
+	DefaultRate *float64           `json:"default_rate,omitempty"`
+}
+
+// RateLimitConfig limits ingest per API key, tenant or source, selected by
+// By. Overrides replace Default for individual keys.
+type RateLimitConfig struct {
+	By        string           `json:"by,omitempty"`
+	Default   Limit            `json:"default"`
+	Overrides map[string]Limit `json:"overrides,omitempty"`
+	UsageFile string           `json:"usage_file,omitempty"`
+}
+
+// Limit is a token bucket refilled at RecordsPerSecond up to Burst, plus
+// daily quotas. Zero values mean unlimited.
+type Limit struct {
+	RecordsPerSecond float64 `json:"records_per_second,omitempty"`
+	Burst            int64   `json:"burst,omitempty"`
+	DailyRecords     int64   `json:"daily_records,omitempty"`
+	DailyBytes       int64   `json:"daily_bytes,omitempty"`
+}
+
+// TenantConfig overrides index settings for one tenant. Tenants that are not
+// listed get their own indexes with the server defaults.
+type TenantConfig struct {
+	IndexPrefix string   `json:"index_prefix,omitempty"`
+	Retention   Duration `json:"retention,omitempty"`
+}
+
+// SourceConfig holds the settings for one input source, e.g. "http" or "gelf".
+type SourceConfig struct {
+	Pipeline string `json:"pipeline"`
+	// Format is the default parser for raw lines from this source, see
+	// pkg/parser. Requests may override it with ?format=.
+	Format string `json:"format,omitempty"`
+	// Multiline merges continuation lines, e.g. stack traces, into the
+	// preceding log of the same stream.
+	Multiline *MultilineConfig `json:"multiline,omitempty"`
+	// Tenant receives logs from inputs without request headers, e.g. gelf.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// MultilineConfig decides which lines continue the previous event. With
+// Continuation set, matching lines are continuations; otherwise lines not
+// matching Start are.
+type MultilineConfig struct {
+	Start        string   `json:"start,omitempty"`
+	Continuation string   `json:"continuation,omitempty"`
+	FlushTimeout Duration `json:"flush_timeout,omitempty"`
+	MaxLines     int      `json:"max_lines,omitempty"`
+}
+
+// ProcessorConfig declares one pipeline step. Which options apply depends on
+// Type.
+type ProcessorConfig struct {
+	Type string     `json:"type"`
+	If   *Condition `json:"if,omitempty"`
+
+	Field   string                 `json:"field,omitempty"`
+	Target  string                 `json:"target,omitempty"`
+	Pattern string                 `json:"pattern,omitempty"`
+	From    string                 `json:"from,omitempty"`
+	To      string                 `json:"to,omitempty"`
+	Value   interface{}            `json:"value,omitempty"`
+	Fields  []string               `json:"fields,omitempty"`
+	Values  map[string]interface{} `json:"values,omitempty"`
+	Mapping map[string]string      `json:"mapping,omitempty"`
+	Routes  []RouteConfig          `json:"routes,omitempty"`
+}
+
+// Condition matches a log by one of its fields. All set options must match.
+type Condition struct {
+	Field   string  `json:"field"`
+	Equals  *string `json:"equals,omitempty"`
+	Matches string  `json:"matches,omitempty"`
+	Exists  *bool   `json:"exists,omitempty"`
+}
+
+// RouteConfig sends matching logs through another named pipeline.
+type RouteConfig struct {
+	If       *Condition `json:"if,omitempty"`
+	Pipeline string     `json:"pipeline"`
+}
+
+// RedactionConfig masks sensitive data before logs are indexed. Fields limits
+// redaction to the named fields; by default the message and every structured
+// field are redacted.
+type RedactionConfig struct {
+	Rules    []RedactionRule `json:"rules"`
+	Fields   []string        `json:"fields,omitempty"`
+	HashSalt string          `json:"hash_salt,omitempty"`
+}
+
+// RedactionRule is one of the built-in detectors (email, credit_card, ip,
+// jwt) or a custom regex. Replace is "token" (default) or "hash".
+type RedactionRule struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Pattern string `json:"pattern,omitempty"`
+	Replace string `json:"replace,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+// ShipperConfig lists the sources tailed by `go-logger ship`.
+type ShipperConfig struct {
+	Sources []ShipperSource `json:"sources"`
+}
+
+// ShipperSource is a set of file globs parsed with the same format.
+type ShipperSource struct {
+	Name      string           `json:"name"`
+	Paths     []string         `json:"paths"`
+	Format    string           `json:"format,omitempty"`
+	Multiline *MultilineConfig `json:"multiline,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "5s" in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load reads a config file. An empty path returns an empty config.
+func Load(path string) (*File, error) {
+	cfg := &File{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse config file %v: %w", path, err)
+	}
+	return cfg, nil
+}
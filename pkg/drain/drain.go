@@ -0,0 +1,203 @@
+// Package drain clusters log messages into templates with the Drain
+// algorithm (He et al., "Drain: An Online Log Parsing Approach with Fixed
+// Depth Tree", 2017).
+//
+// Messages are split into tokens and routed through a prefix tree by their
+// token count and first tokens. In the leaf they join the most similar
+// cluster, and the tokens where they differ from its template become
+// wildcards:
+//
+//	m := drain.New(drain.Options{})
+//	m.Add("user 42 logged in from 10.0.0.1")
+//	c := m.Add("user 7 logged in from 10.0.0.2")
+//	c.Template() // "user <*> logged in from <*>"
+package drain
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Wildcard stands for the variable tokens of a template.
+const Wildcard = "<*>"
+
+const (
+	defaultDepth       = 4
+	defaultSimilarity  = 0.4
+	defaultMaxChildren = 100
+	defaultMaxClusters = 10000
+	defaultMaxTokens   = 100
+)
+
+// Options tune the miner. Zero values use the defaults.
+type Options struct {
+	// Depth of the prefix tree, counting the root and the token count
+	// level, so Depth-2 leading tokens route a message. 4 by default.
+	Depth int
+	// Similarity is the share of equal tokens a message needs to join a
+	// cluster, 0.4 by default.
+	Similarity float64
+	// MaxChildren caps the children of a tree node; further tokens share a
+	// wildcard child. 100 by default.
+	MaxChildren int
+	// MaxClusters caps the clusters; Add returns nil for messages that
+	// would need a new one. 10000 by default.
+	MaxClusters int
+	// MaxTokens is how many leading tokens of a message are compared, 100
+	// by default.
+	MaxTokens int
+}
+
+// Cluster is a group of messages sharing a template.
+type Cluster struct {
+	// ID is the position of the cluster in creation order.
+	ID     int
+	Size   int
+	tokens []string
+}
+
+// Template is the message shape of the cluster, with Wildcard for the
+// tokens that vary.
+func (c *Cluster) Template() string {
+	return strings.Join(c.tokens, " ")
+}
+
+type node struct {
+	children map[string]*node
+	clusters []*Cluster
+}
+
+// Miner clusters messages. It is not safe for concurrent use.
+type Miner struct {
+	opts     Options
+	root     *node
+	clusters []*Cluster
+}
+
+func New(opts Options) *Miner {
+	if opts.Depth < 3 {
+		opts.Depth = defaultDepth
+	}
+	if opts.Similarity <= 0 {
+		opts.Similarity = defaultSimilarity
+	}
+	if opts.MaxChildren <= 0 {
+		opts.MaxChildren = defaultMaxChildren
+	}
+	if opts.MaxClusters <= 0 {
+		opts.MaxClusters = defaultMaxClusters
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = defaultMaxTokens
+	}
+	return &Miner{opts: opts, root: &node{children: map[string]*node{}}}
+}
+
+// Add adds a message to the most similar cluster, or to a new one, and
+// returns the cluster.
+func (m *Miner) Add(message string) *Cluster {
+	tokens := m.tokenize(message)
+	leaf := m.leaf(tokens)
+
+	if cluster := m.bestMatch(leaf, tokens); cluster != nil {
+		for i, token := range tokens {
+			if cluster.tokens[i] != token {
+				cluster.tokens[i] = Wildcard
+			}
+		}
+		cluster.Size++
+		return cluster
+	}
+
+	if len(m.clusters) >= m.opts.MaxClusters {
+		return nil
+	}
+	cluster := &Cluster{ID: len(m.clusters), Size: 1, tokens: tokens}
+	m.clusters = append(m.clusters, cluster)
+	leaf.clusters = append(leaf.clusters, cluster)
+	return cluster
+}
+
+// Clusters returns every cluster in creation order.
+func (m *Miner) Clusters() []*Cluster {
+	return m.clusters
+}
+
+// tokenize splits a message on whitespace and masks tokens holding digits,
+// which are almost always variable: IDs, numbers, addresses, times.
+func (m *Miner) tokenize(message string) []string {
+	tokens := strings.Fields(message)
+	if len(tokens) > m.opts.MaxTokens {
+		tokens = tokens[:m.opts.MaxTokens]
+	}
+	for i, token := range tokens {
+		if strings.IndexFunc(token, unicode.IsDigit) >= 0 {
+			tokens[i] = Wildcard
+		}
+	}
+	return tokens
+}
+
+// leaf walks the tree by token count and leading tokens, adding the missing
+// nodes.
+func (m *Miner) leaf(tokens []string) *node {
+	keys := []string{lengthKey(len(tokens))}
+	for i := 0; i < len(tokens) && i < m.opts.Depth-2; i++ {
+		keys = append(keys, tokens[i])
+	}
+
+	current := m.root
+	for depth, key := range keys {
+		next, ok := current.children[key]
+		if !ok && depth > 0 {
+			// the token count level is never capped
+			if len(current.children) >= m.opts.MaxChildren {
+				key = Wildcard
+			}
+			next, ok = current.children[key]
+		}
+		if !ok {
+			next = &node{children: map[string]*node{}}
+			current.children[key] = next
+		}
+		current = next
+	}
+	return current
+}
+
+func lengthKey(n int) string {
+	return "#" + strconv.Itoa(n)
+}
+
+// bestMatch returns the cluster in leaf most similar to tokens, if similar
+// enough. Ties go to the cluster with more wildcards, the more general one.
+func (m *Miner) bestMatch(leaf *node, tokens []string) *Cluster {
+	var best *Cluster
+	bestSimilarity, bestWildcards := -1.0, -1
+	for _, cluster := range leaf.clusters {
+		if len(cluster.tokens) != len(tokens) {
+			continue
+		}
+		equal, wildcards := 0, 0
+		for i, token := range cluster.tokens {
+			switch {
+			case token == Wildcard:
+				wildcards++
+			case token == tokens[i]:
+				equal++
+			}
+		}
+		similarity := 1.0
+		if len(tokens) > 0 {
+			similarity = float64(equal) / float64(len(tokens))
+		}
+		if similarity > bestSimilarity || similarity == bestSimilarity && wildcards > bestWildcards {
+			best, bestSimilarity, bestWildcards = cluster, similarity, wildcards
+		}
+	}
+	if best == nil || bestSimilarity < m.opts.Similarity {
+		return nil
+	}
+	return best
+}
@@ -0,0 +1,122 @@
+package drain
+
+import "testing"
+
+// templates adds messages to a new miner and returns the final template of
+// the cluster each joined, or "" when none could be made.
+func templates(opts Options, messages ...string) (*Miner, []string) {
+	m := New(opts)
+	var clusters []*Cluster
+	for _, message := range messages {
+		clusters = append(clusters, m.Add(message))
+	}
+	var got []string
+	for _, c := range clusters {
+		if c != nil {
+			got = append(got, c.Template())
+		} else {
+			got = append(got, "")
+		}
+	}
+	return m, got
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		messages []string
+		// want is the final template of the cluster of each message.
+		want     []string
+		clusters int
+	}{
+		{
+			name:     "differing tokens become wildcards",
+			messages: []string{"user 42 logged in from alpha", "user 7 logged in from beta"},
+			want:     []string{"user <*> logged in from <*>", "user <*> logged in from <*>"},
+			clusters: 1,
+		},
+		{
+			name:     "tokens with digits are masked",
+			messages: []string{"took 15ms on node-3", "42 retries left"},
+			want:     []string{"took <*> on <*>", "<*> retries left"},
+			clusters: 2,
+		},
+		{
+			name:     "token counts never mix",
+			messages: []string{"cache miss", "cache miss for key"},
+			want:     []string{"cache miss", "cache miss for key"},
+			clusters: 2,
+		},
+		{
+			name:     "similar enough at the default threshold",
+			messages: []string{"conn open host alpha port", "conn open dropped by peer"},
+			want:     []string{"conn open <*> <*> <*>", "conn open <*> <*> <*>"},
+			clusters: 1,
+		},
+		{
+			name:     "below a higher threshold",
+			opts:     Options{Similarity: 0.5},
+			messages: []string{"conn open host alpha port", "conn open dropped by peer"},
+			want:     []string{"conn open host alpha port", "conn open dropped by peer"},
+			clusters: 2,
+		},
+		{
+			name:     "only the leading tokens route",
+			messages: []string{"disk full on sda", "disk nearly full on"},
+			want:     []string{"disk full on sda", "disk nearly full on"},
+			clusters: 2,
+		},
+		{
+			name:     "max tokens",
+			opts:     Options{MaxTokens: 3},
+			messages: []string{"job done in a second", "job done in two hours"},
+			want:     []string{"job done in", "job done in"},
+			clusters: 1,
+		},
+		{
+			name:     "max clusters",
+			opts:     Options{MaxClusters: 1},
+			messages: []string{"worker alpha started", "disk full on sda", "worker alpha stopped"},
+			want:     []string{"worker alpha <*>", "", "worker alpha <*>"},
+			clusters: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, got := templates(tt.opts, tt.messages...)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("template of %q = %q, want %q", tt.messages[i], got[i], tt.want[i])
+				}
+			}
+			if len(m.Clusters()) != tt.clusters {
+				t.Errorf("%d clusters, want %d", len(m.Clusters()), tt.clusters)
+			}
+		})
+	}
+}
+
+func TestMaxChildrenRoutesToWildcard(t *testing.T) {
+	m, got := templates(Options{Depth: 3, MaxChildren: 2},
+		"alpha started", "beta started", "gamma started", "delta started", "alpha started")
+	// gamma and delta share the wildcard child and so one cluster
+	want := []string{"alpha started", "beta started", "<*> started", "<*> started", "alpha started"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d joined %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	root := m.root.children[lengthKey(2)]
+	if len(root.children) != 3 || root.children[Wildcard] == nil {
+		t.Errorf("children = %v, want alpha, beta and a wildcard", root.children)
+	}
+	sizes := []int{}
+	for _, c := range m.Clusters() {
+		sizes = append(sizes, c.Size)
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 1 || sizes[2] != 2 {
+		t.Errorf("cluster sizes = %v, want [2 1 2]", sizes)
+	}
+}
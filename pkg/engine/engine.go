@@ -0,0 +1,199 @@
+// Package engine runs go-logger in-process, without HTTP or NATS:
+//
+//	e, err := engine.New(engine.Options{IndexPath: "data/index"})
+//	...
+//	defer e.Close()
+//	e.Ingest(ctx, types.LogFormat{Level: "info", Message: "hello"})
+//	e.Flush(ctx)
+//	result, err := e.Search(ctx, types.SearchFormat{Query: "hello"})
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/app"
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/queue"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+const (
+	defaultRetention = 12 * 24 * time.Hour
+	defaultSource    = "engine"
+)
+
+var ErrClosed = errors.New("engine closed")
+
+type Options struct {
+	// IndexPath is the base name of the hourly indexes, e.g. "data/index".
+	IndexPath string
+	Retention time.Duration
+	// Config declares pipelines, sampling, redaction and tenants. When nil
+	// it is loaded from ConfigFile, if set.
+	Config     *config.File
+	ConfigFile string
+	// Queue between ingest and indexing. It defaults to an in-memory queue.
+	Queue queue.Queue
+	// Source picks the pipeline applied by Ingest.
+	Source string
+}
+
+// Engine owns a queue, per-tenant indexes and a log processor.
+type Engine struct {
+	app    *app.App
+	source string
+
+	mu      sync.Mutex
+	pending int
+	waiters []chan struct{}
+	closed  bool
+}
+
+func New(opts Options) (*Engine, error) {
+	if opts.IndexPath == "" {
+		return nil, errors.New("index path is required")
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultRetention
+	}
+	if opts.Source == "" {
+		opts.Source = defaultSource
+	}
+	fileCfg := opts.Config
+	if fileCfg == nil {
+		var err error
+		fileCfg, err = config.Load(opts.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	logQueue := opts.Queue
+	if logQueue == nil {
+		logQueue = queue.NewChannelQueue()
+	}
+
+	e := &Engine{source: opts.Source}
+	a, err := app.NewAppWithQueue(app.Config{
+		IndexName:     opts.IndexPath,
+		RetentionDays: opts.Retention,
+	}, fileCfg, &trackedQueue{Queue: logQueue, engine: e})
+	if err != nil {
+		return nil, err
+	}
+	a.AfterIndex(func(string, types.LogFormat, error) {
+		e.track(-1)
+	})
+	if err := a.Start(); err != nil {
+		return nil, err
+	}
+	e.app = a
+	return e, nil
+}
+
+type tenantKey struct{}
+
+// WithTenant makes Ingest and Search act as tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// Ingest queues logs for indexing. Logs without a tenant get the tenant of
+// ctx. Indexing is asynchronous; use Flush to wait for it.
+func (e *Engine) Ingest(ctx context.Context, logs types.LogFormat) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	closed := e.closed
+	e.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	if logs.Timestamp.IsZero() {
+		logs.Timestamp = time.Now()
+	}
+	if logs.Tenant == "" {
+		logs.Tenant = tenantFromContext(ctx)
+	}
+	return e.app.Ingest(e.source, logs)
+}
+
+// Search searches the indexes of the tenant of ctx.
+func (e *Engine) Search(ctx context.Context, req types.SearchFormat) (*types.SearchResponse, error) {
+	return e.app.Search(ctx, tenantFromContext(ctx), req)
+}
+
+// Flush waits until every queued log has been indexed. Logs still held by
+// a multiline aggregator are not waited for.
+func (e *Engine) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	if e.pending == 0 {
+		e.mu.Unlock()
+		return nil
+	}
+	done := make(chan struct{})
+	e.waiters = append(e.waiters, done)
+	e.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close indexes the queued logs, including those held by a multiline
+// aggregator, and closes the indexes.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return ErrClosed
+	}
+	e.closed = true
+	e.mu.Unlock()
+
+	flushErr := e.app.FlushMultiline()
+	if err := e.Flush(context.Background()); err != nil {
+		flushErr = errors.Join(flushErr, err)
+	}
+	return errors.Join(flushErr, e.app.Shutdown())
+}
+
+func (e *Engine) track(delta int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.pending += delta
+	if e.pending == 0 {
+		for _, done := range e.waiters {
+			close(done)
+		}
+		e.waiters = nil
+	}
+}
+
+// trackedQueue counts queued logs so Flush knows when all are indexed.
+type trackedQueue struct {
+	queue.Queue
+	engine *Engine
+}
+
+func (q *trackedQueue) Enqueue(logs types.LogFormat) error {
+	q.engine.track(1)
+	if err := q.Queue.Enqueue(logs); err != nil {
+		q.engine.track(-1)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+func newTestEngine(t *testing.T, indexPath string, cfg *config.File) *Engine {
+	t.Helper()
+	if cfg == nil {
+		cfg = &config.File{}
+	}
+	e, err := New(Options{IndexPath: indexPath, Config: cfg})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return e
+}
+
+func search(t *testing.T, e *Engine, ctx context.Context, query string) []types.LogFormat {
+	t.Helper()
+	result, err := e.Search(ctx, types.SearchFormat{Query: query})
+	if err != nil {
+		t.Fatalf("Search(%q): %v", query, err)
+	}
+	logs := make([]types.LogFormat, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		logs = append(logs, hit.Log())
+	}
+	return logs
+}
+
+func TestIngestFlushSearch(t *testing.T) {
+	e := newTestEngine(t, filepath.Join(t.TempDir(), "index"), nil)
+	defer e.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, message := range []string{"payment failed", "payment ok", "user logged in"} {
+		if err := e.Ingest(ctx, types.LogFormat{Level: "info", Message: message}); err != nil {
+			t.Fatalf("Ingest: %v", err)
+		}
+	}
+	teamCtx := WithTenant(ctx, "team-a")
+	if err := e.Ingest(teamCtx, types.LogFormat{Level: "error", Message: "payment declined"}); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if err := e.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if got := search(t, e, ctx, "payment"); len(got) != 2 {
+		t.Errorf("default tenant found %d payment logs, want 2: %+v", len(got), got)
+	}
+	got := search(t, e, teamCtx, "payment")
+	if len(got) != 1 || got[0].Message != "payment declined" {
+		t.Errorf("team-a found %+v, want only its own log", got)
+	}
+}
+
+func TestCloseFlushesMultiline(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "index")
+	cfg := &config.File{Sources: map[string]config.SourceConfig{
+		defaultSource: {Multiline: &config.MultilineConfig{Start: `^\S`, FlushTimeout: config.Duration(time.Hour)}},
+	}}
+	e := newTestEngine(t, indexPath, cfg)
+
+	ctx := context.Background()
+	for _, message := range []string{"panic: nil map", "  at main.go:12", "  at server.go:40"} {
+		if err := e.Ingest(ctx, types.LogFormat{Level: "error", Message: message}); err != nil {
+			t.Fatalf("Ingest: %v", err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := e.Ingest(ctx, types.LogFormat{Message: "late"}); err != ErrClosed {
+		t.Errorf("Ingest after Close = %v, want ErrClosed", err)
+	}
+
+	reopened := newTestEngine(t, indexPath, nil)
+	defer reopened.Close()
+	got := search(t, reopened, ctx, "panic")
+	want := "panic: nil map\n  at main.go:12\n  at server.go:40"
+	if len(got) != 1 || got[0].Message != want {
+		t.Errorf("found %+v, want one merged event %q", got, want)
+	}
+}
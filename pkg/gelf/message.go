@@ -0,0 +1,130 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// maxMessageSize caps a GELF document after decompression, so a small
+// compressed payload cannot expand without bound.
+const maxMessageSize = 1 << 20
+
+// syslog severities as used by the GELF "level" field
+var syslogLevels = map[int]string{
+	0: "emergency",
+	1: "alert",
+	2: "critical",
+	3: "error",
+	4: "warn",
+	5: "notice",
+	6: "info",
+	7: "debug",
+}
+
+// decompress detects gzip or zlib payloads by their magic bytes and returns
+// the plain JSON document. Uncompressed payloads are returned as is. Payloads
+// that decompress to more than maxMessageSize bytes are rejected.
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 2 {
+		return data, nil
+	}
+
+	var reader io.ReadCloser
+	var err error
+	switch {
+	case data[0] == 0x1f && data[1] == 0x8b:
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+	case data[0] == 0x78 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0:
+		reader, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open compressed payload: %w", err)
+	}
+	defer reader.Close()
+
+	payload, err := io.ReadAll(io.LimitReader(reader, maxMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress payload: %w", err)
+	}
+	if len(payload) > maxMessageSize {
+		return nil, fmt.Errorf("decompressed payload exceeds %d bytes", maxMessageSize)
+	}
+	return payload, nil
+}
+
+// Parse converts a (possibly compressed) GELF document into a LogFormat.
+func Parse(data []byte) (types.LogFormat, error) {
+	payload, err := decompress(data)
+	if err != nil {
+		return types.LogFormat{}, err
+	}
+
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return types.LogFormat{}, fmt.Errorf("cannot decode gelf message: %w", err)
+	}
+
+	shortMessage, _ := raw["short_message"].(string)
+	if shortMessage == "" {
+		return types.LogFormat{}, errors.New("gelf message has no short_message")
+	}
+
+	logs := types.LogFormat{
+		Timestamp: time.Now().UTC(),
+		Level:     syslogLevels[6],
+		Message:   shortMessage,
+		Fields:    map[string]interface{}{},
+	}
+
+	if ts, ok := raw["timestamp"].(json.Number); ok {
+		if seconds, err := ts.Float64(); err == nil {
+			sec, frac := math.Modf(seconds)
+			logs.Timestamp = time.Unix(int64(sec), int64(math.Round(frac*1e3))*int64(time.Millisecond)).UTC()
+		}
+	}
+
+	if lvl, ok := raw["level"].(json.Number); ok {
+		if n, err := lvl.Int64(); err == nil {
+			if name, ok := syslogLevels[int(n)]; ok {
+				logs.Level = name
+			}
+		}
+	}
+
+	for key, value := range raw {
+		switch {
+		case key == "full_message" || key == "host":
+			logs.Fields[key] = value
+		case strings.HasPrefix(key, "_") && key != "_id":
+			logs.Fields[strings.TrimPrefix(key, "_")] = normalizeNumber(value)
+		}
+	}
+
+	return logs, nil
+}
+
+// normalizeNumber turns json.Number values into float64 so they are indexed
+// as numeric fields.
+func normalizeNumber(value interface{}) interface{} {
+	if n, ok := value.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+		return n.String()
+	}
+	return value
+}
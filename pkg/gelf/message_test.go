@@ -0,0 +1,118 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"strings"
+	"testing"
+	"time"
+)
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zlibbed(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("zlib: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("zlib: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseCompression(t *testing.T) {
+	doc := []byte(`{"version": "1.1", "host": "web-1", "short_message": "hello", "level": 3, "_service": "api"}`)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"plain", doc},
+		{"gzip", gzipped(t, doc)},
+		{"zlib", zlibbed(t, doc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := Parse(tt.data)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if logs.Message != "hello" || logs.Level != "error" {
+				t.Errorf("got message %q level %q, want hello error", logs.Message, logs.Level)
+			}
+			if logs.Fields["service"] != "api" || logs.Fields["host"] != "web-1" {
+				t.Errorf("fields = %v", logs.Fields)
+			}
+		})
+	}
+}
+
+func TestParseDecompressedSizeLimit(t *testing.T) {
+	message := strings.Repeat("a", maxMessageSize)
+	doc := []byte(`{"short_message": "` + message + `"}`)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"gzip", gzipped(t, doc)},
+		{"zlib", zlibbed(t, doc)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.data) >= maxMessageSize {
+				t.Fatalf("compressed payload of %d bytes is not small", len(tt.data))
+			}
+			if _, err := Parse(tt.data); err == nil || !strings.Contains(err.Error(), "exceeds") {
+				t.Fatalf("Parse error = %v, want size limit", err)
+			}
+		})
+	}
+
+	// a document at the limit still decompresses
+	fits := []byte(`{"short_message": "` + strings.Repeat("a", maxMessageSize-len(`{"short_message": ""}`)) + `"}`)
+	if _, err := Parse(gzipped(t, fits)); err != nil {
+		t.Fatalf("Parse at the limit: %v", err)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	logs, err := Parse([]byte(`{"short_message": "hi", "timestamp": 1700000000.25, "level": 9, "_id": "x", "_latency": 12.5, "ignored": 1}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if want := time.Unix(1700000000, 250*int64(time.Millisecond)).UTC(); !logs.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", logs.Timestamp, want)
+	}
+	if logs.Level != "info" {
+		t.Errorf("unknown level = %q, want info", logs.Level)
+	}
+	if _, ok := logs.Fields["id"]; ok {
+		t.Errorf("_id must not be kept: %v", logs.Fields)
+	}
+	if _, ok := logs.Fields["ignored"]; ok {
+		t.Errorf("fields without _ must not be kept: %v", logs.Fields)
+	}
+	if logs.Fields["latency"] != 12.5 {
+		t.Errorf("latency = %#v, want 12.5", logs.Fields["latency"])
+	}
+
+	if _, err := Parse([]byte(`{"host": "web-1"}`)); err == nil {
+		t.Error("Parse without short_message succeeded")
+	}
+}
@@ -0,0 +1,278 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+const (
+	maxChunks       = 128
+	maxUDPSize      = 65536
+	chunkTimeout    = 5 * time.Second
+	chunkHeaderSize = 12
+	// maxPending caps the chunked messages being reassembled at once.
+	maxPending = 1024
+)
+
+var chunkMagic = []byte{0x1e, 0x0f}
+
+// Handler receives every decoded GELF message.
+type Handler func(logs types.LogFormat) error
+
+type chunkSet struct {
+	chunks   [][]byte
+	received int
+	created  time.Time
+}
+
+type Server struct {
+	udpAddr string
+	tcpAddr string
+	handler Handler
+
+	udpConn     net.PacketConn
+	tcpListener net.Listener
+
+	mu      sync.Mutex
+	pending map[string]*chunkSet
+	wg      sync.WaitGroup
+	done    chan struct{}
+}
+
+// NewServer creates a GELF receiver. An empty address disables that transport.
+func NewServer(udpAddr, tcpAddr string, handler Handler) *Server {
+	return &Server{
+		udpAddr: udpAddr,
+		tcpAddr: tcpAddr,
+		handler: handler,
+		pending: map[string]*chunkSet{},
+		done:    make(chan struct{}),
+	}
+}
+
+func (s *Server) Start() error {
+	if s.udpAddr != "" {
+		conn, err := net.ListenPacket("udp", s.udpAddr)
+		if err != nil {
+			return err
+		}
+		s.udpConn = conn
+		log.Printf("Starting GELF UDP input on: %v", conn.LocalAddr())
+
+		s.wg.Add(2)
+		go s.serveUDP()
+		go s.expireChunks()
+	}
+
+	if s.tcpAddr != "" {
+		listener, err := net.Listen("tcp", s.tcpAddr)
+		if err != nil {
+			if s.udpConn != nil {
+				s.udpConn.Close()
+			}
+			return err
+		}
+		s.tcpListener = listener
+		log.Printf("Starting GELF TCP input on: %v", listener.Addr())
+
+		s.wg.Add(1)
+		go s.serveTCP()
+	}
+
+	return nil
+}
+
+func (s *Server) Shutdown() {
+	close(s.done)
+	if s.udpConn != nil {
+		s.udpConn.Close()
+	}
+	if s.tcpListener != nil {
+		s.tcpListener.Close()
+	}
+	s.wg.Wait()
+	log.Println("GELF input Shutdown.")
+}
+
+func (s *Server) handle(data []byte) {
+	logs, err := Parse(data)
+	if err != nil {
+		log.Printf("Cannot parse GELF message. Error: %v", err)
+		return
+	}
+	if err := s.handler(logs); err != nil {
+		log.Printf("Cannot handle GELF message. Error: %v", err)
+	}
+}
+
+func (s *Server) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxUDPSize)
+	for {
+		n, _, err := s.udpConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("GELF UDP read error: %v", err)
+			continue
+		}
+
+		packet := make([]byte, n)
+		copy(packet, buf[:n])
+
+		if bytes.HasPrefix(packet, chunkMagic) {
+			if message := s.addChunk(packet); message != nil {
+				s.handle(message)
+			}
+			continue
+		}
+		s.handle(packet)
+	}
+}
+
+// addChunk stores a chunk and returns the reassembled message once all
+// chunks of it have arrived.
+func (s *Server) addChunk(packet []byte) []byte {
+	if len(packet) < chunkHeaderSize {
+		log.Printf("Dropping short GELF chunk of %d bytes", len(packet))
+		return nil
+	}
+
+	id := string(packet[2:10])
+	seq := int(packet[10])
+	count := int(packet[11])
+	if count == 0 || count > maxChunks || seq >= count {
+		log.Printf("Dropping GELF chunk with invalid sequence %d/%d", seq, count)
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, ok := s.pending[id]
+	if !ok {
+		if len(s.pending) >= maxPending {
+			log.Printf("Dropping GELF chunk, %d messages are already pending", maxPending)
+			return nil
+		}
+		set = &chunkSet{
+			chunks:  make([][]byte, count),
+			created: time.Now(),
+		}
+		s.pending[id] = set
+	}
+	if len(set.chunks) != count || set.chunks[seq] != nil {
+		return nil
+	}
+
+	set.chunks[seq] = packet[chunkHeaderSize:]
+	set.received++
+	if set.received < count {
+		return nil
+	}
+
+	delete(s.pending, id)
+	return bytes.Join(set.chunks, nil)
+}
+
+// expireChunks drops incomplete messages, as required by the GELF spec.
+func (s *Server) expireChunks() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(chunkTimeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.expire(now)
+		}
+	}
+}
+
+// expire drops the messages whose first chunk arrived more than
+// chunkTimeout before now.
+func (s *Server) expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, set := range s.pending {
+		if now.Sub(set.created) > chunkTimeout {
+			log.Printf("Dropping incomplete GELF message after %v", chunkTimeout)
+			delete(s.pending, id)
+		}
+	}
+}
+
+func (s *Server) serveTCP() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.tcpListener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("GELF TCP accept error: %v", err)
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-s.done:
+			conn.Close()
+		case <-closed:
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	scanner.Split(splitNull)
+
+	for scanner.Scan() {
+		message := bytes.TrimSpace(scanner.Bytes())
+		if len(message) == 0 {
+			continue
+		}
+		s.handle(message)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("GELF TCP connection error: %v", err)
+	}
+}
+
+// splitNull is a bufio.SplitFunc for null-byte delimited GELF TCP frames.
+func splitNull(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,183 @@
+package gelf
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// chunk builds a GELF chunk of message id with sequence number seq of count.
+func chunk(id string, seq, count int, data string) []byte {
+	packet := append([]byte{}, chunkMagic...)
+	packet = append(packet, []byte(id)...)
+	packet = append(packet, byte(seq), byte(count))
+	return append(packet, data...)
+}
+
+func TestAddChunk(t *testing.T) {
+	tests := []struct {
+		name    string
+		packets [][]byte
+		want    string
+	}{
+		{
+			name:    "in order",
+			packets: [][]byte{chunk("msg-0001", 0, 3, "a"), chunk("msg-0001", 1, 3, "b"), chunk("msg-0001", 2, 3, "c")},
+			want:    "abc",
+		},
+		{
+			name:    "out of order",
+			packets: [][]byte{chunk("msg-0001", 2, 3, "c"), chunk("msg-0001", 0, 3, "a"), chunk("msg-0001", 1, 3, "b")},
+			want:    "abc",
+		},
+		{
+			name:    "duplicate chunk is ignored",
+			packets: [][]byte{chunk("msg-0001", 0, 2, "a"), chunk("msg-0001", 0, 2, "x"), chunk("msg-0001", 1, 2, "b")},
+			want:    "ab",
+		},
+		{
+			name:    "sequence out of range is dropped",
+			packets: [][]byte{chunk("msg-0001", 0, 2, "a"), chunk("msg-0001", 2, 2, "x"), chunk("msg-0001", 1, 2, "b")},
+			want:    "ab",
+		},
+		{
+			name:    "chunk with a different count is dropped",
+			packets: [][]byte{chunk("msg-0001", 0, 2, "a"), chunk("msg-0001", 1, 3, "x"), chunk("msg-0001", 1, 2, "b")},
+			want:    "ab",
+		},
+		{
+			name:    "too many chunks",
+			packets: [][]byte{chunk("msg-0001", 0, maxChunks+1, "a")},
+		},
+		{
+			name:    "zero chunks",
+			packets: [][]byte{chunk("msg-0001", 0, 0, "a")},
+		},
+		{
+			name:    "short header",
+			packets: [][]byte{chunkMagic},
+		},
+		{
+			name:    "incomplete",
+			packets: [][]byte{chunk("msg-0001", 0, 2, "a")},
+		},
+		{
+			name:    "messages are kept apart by id",
+			packets: [][]byte{chunk("msg-0001", 0, 2, "a"), chunk("msg-0002", 1, 2, "y"), chunk("msg-0002", 0, 2, "x")},
+			want:    "xy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("", "", nil)
+			var got []string
+			for _, packet := range tt.packets {
+				if message := s.addChunk(packet); message != nil {
+					got = append(got, string(message))
+				}
+			}
+			var want []string
+			if tt.want != "" {
+				want = []string{tt.want}
+			}
+			if len(got) != len(want) || (len(got) == 1 && got[0] != want[0]) {
+				t.Errorf("messages = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestExpireChunks(t *testing.T) {
+	s := NewServer("", "", nil)
+	s.addChunk(chunk("msg-0001", 0, 2, "a"))
+
+	s.expire(time.Now())
+	if len(s.pending) != 1 {
+		t.Fatalf("pending = %d before the timeout, want 1", len(s.pending))
+	}
+
+	s.expire(time.Now().Add(chunkTimeout + time.Second))
+	if len(s.pending) != 0 {
+		t.Fatalf("pending = %d after the timeout, want 0", len(s.pending))
+	}
+	// the rest of an expired message does not complete it
+	if message := s.addChunk(chunk("msg-0001", 1, 2, "b")); message != nil {
+		t.Fatalf("expired message was completed: %q", message)
+	}
+}
+
+func TestMaxPending(t *testing.T) {
+	s := NewServer("", "", nil)
+	for i := 0; i < maxPending; i++ {
+		id := []byte("msg-0000")
+		id[6], id[7] = byte(i>>8), byte(i)
+		s.addChunk(chunk(string(id), 0, 2, "a"))
+	}
+	s.addChunk(chunk("overflow", 0, 2, "a"))
+	if len(s.pending) != maxPending {
+		t.Fatalf("pending = %d, want %d", len(s.pending), maxPending)
+	}
+	if _, ok := s.pending["overflow"]; ok {
+		t.Fatal("chunk beyond maxPending was kept")
+	}
+}
+
+func TestServeUDPChunked(t *testing.T) {
+	received := make(chan types.LogFormat, 1)
+	s := NewServer("127.0.0.1:0", "", func(logs types.LogFormat) error {
+		received <- logs
+		return nil
+	})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer s.Shutdown()
+
+	conn, err := net.Dial("udp", s.udpConn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	payload := gzipped(t, []byte(`{"short_message": "chunked over udp"}`))
+	half := len(payload) / 2
+	for i, part := range [][]byte{payload[half:], payload[:half]} {
+		if _, err := conn.Write(chunk("udp-0001", 1-i, 2, string(part))); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	select {
+	case logs := <-received:
+		if logs.Message != "chunked over udp" {
+			t.Errorf("message = %q", logs.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestSplitNull(t *testing.T) {
+	var got [][]byte
+	data := []byte("one\x00two\x00three")
+	for len(data) > 0 {
+		advance, token, err := splitNull(data, true)
+		if err != nil {
+			t.Fatalf("splitNull: %v", err)
+		}
+		got = append(got, token)
+		data = data[advance:]
+	}
+	want := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	if len(got) != len(want) {
+		t.Fatalf("tokens = %q, want %q", got, want)
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("token %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
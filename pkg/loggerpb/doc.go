@@ -0,0 +1,4 @@
+// Package loggerpb holds the Go code generated from api/logger.proto.
+package loggerpb
+
+//go:generate protoc -I ../../api --go_out=. --go_opt=module=github.com/adiyakaihsan/go-logger/pkg/loggerpb --go-grpc_out=. --go-grpc_opt=module=github.com/adiyakaihsan/go-logger/pkg/loggerpb logger.proto
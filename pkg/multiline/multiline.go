@@ -0,0 +1,226 @@
+package multiline
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+const (
+	defaultFlushTimeout = 5 * time.Second
+	defaultMaxLines     = 500
+	maxRetryEvents      = 1000
+)
+
+// ErrBacklogFull is returned by Add, without taking the line, while too many
+// events wait to be emitted again.
+var ErrBacklogFull = errors.New("too many multiline events waiting to be emitted")
+
+// EmitFunc receives merged events. ref is the value passed to Add with the
+// last line merged into the event.
+type EmitFunc func(stream string, logs types.LogFormat, ref interface{}) error
+
+type event struct {
+	stream  string
+	logs    types.LogFormat
+	ref     interface{}
+	lines   int
+	updated time.Time
+}
+
+// Aggregator merges continuation lines into the preceding event of the same
+// stream. Events are emitted when the next event starts, when MaxLines is
+// reached or after FlushTimeout without new lines. An event whose emit fails
+// is kept and emitted again with the next flush.
+type Aggregator struct {
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	flushTimeout time.Duration
+	maxLines     int
+	emit         EmitFunc
+
+	mu      sync.Mutex
+	pending map[string]*event
+	retry   []*event
+	failed  atomic.Int64
+	dropped atomic.Int64
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func New(cfg config.MultilineConfig, emit EmitFunc) (*Aggregator, error) {
+	if cfg.Start == "" && cfg.Continuation == "" {
+		return nil, errors.New("multiline requires a start or continuation pattern")
+	}
+
+	a := &Aggregator{
+		flushTimeout: time.Duration(cfg.FlushTimeout),
+		maxLines:     cfg.MaxLines,
+		emit:         emit,
+		pending:      map[string]*event{},
+		done:         make(chan struct{}),
+	}
+	if a.flushTimeout <= 0 {
+		a.flushTimeout = defaultFlushTimeout
+	}
+	if a.maxLines <= 0 {
+		a.maxLines = defaultMaxLines
+	}
+
+	var err error
+	if cfg.Start != "" {
+		if a.start, err = regexp.Compile(cfg.Start); err != nil {
+			return nil, fmt.Errorf("invalid multiline start pattern: %w", err)
+		}
+	}
+	if cfg.Continuation != "" {
+		if a.continuation, err = regexp.Compile(cfg.Continuation); err != nil {
+			return nil, fmt.Errorf("invalid multiline continuation pattern: %w", err)
+		}
+	}
+	return a, nil
+}
+
+func (a *Aggregator) isContinuation(message string) bool {
+	if a.continuation != nil {
+		return a.continuation.MatchString(message)
+	}
+	return !a.start.MatchString(message)
+}
+
+// Add merges logs into the pending event of stream or starts a new one. An
+// event it completes but cannot emit is kept for the next flush, so Add only
+// fails with ErrBacklogFull, in which case logs was not taken.
+func (a *Aggregator) Add(stream string, logs types.LogFormat, ref interface{}) error {
+	var flushed *event
+
+	a.mu.Lock()
+	if len(a.retry) >= maxRetryEvents {
+		a.mu.Unlock()
+		return ErrBacklogFull
+	}
+	current, ok := a.pending[stream]
+	switch {
+	case ok && a.isContinuation(logs.Message):
+		current.logs.Message += "\n" + logs.Message
+		current.ref = ref
+		current.lines++
+		current.updated = time.Now()
+		if current.lines >= a.maxLines {
+			flushed = current
+			delete(a.pending, stream)
+		}
+	default:
+		flushed = current
+		a.pending[stream] = &event{stream: stream, logs: logs, ref: ref, lines: 1, updated: time.Now()}
+	}
+	a.mu.Unlock()
+
+	if flushed != nil {
+		a.emitEvent(flushed)
+	}
+	return nil
+}
+
+// emitEvent emits e and keeps it for the next flush if that fails.
+func (a *Aggregator) emitEvent(e *event) error {
+	err := a.emit(e.stream, e.logs, e.ref)
+	if err != nil {
+		a.failed.Add(1)
+		a.mu.Lock()
+		a.retry = append(a.retry, e)
+		a.mu.Unlock()
+	}
+	return err
+}
+
+// Failed is the number of emits that failed, including retries.
+func (a *Aggregator) Failed() int64 {
+	return a.failed.Load()
+}
+
+// Retrying is the number of events waiting to be emitted again.
+func (a *Aggregator) Retrying() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.retry)
+}
+
+// Dropped is the number of events still failing when the aggregator closed.
+func (a *Aggregator) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+// FlushExpired emits events that got no new line within the flush timeout.
+func (a *Aggregator) FlushExpired() {
+	a.flush(func(e *event) bool {
+		return time.Since(e.updated) >= a.flushTimeout
+	})
+}
+
+// Flush emits every pending event and returns the emit errors. Events that
+// failed are kept for the next flush.
+func (a *Aggregator) Flush() error {
+	return a.flush(func(*event) bool { return true })
+}
+
+// flush emits the events waiting for a retry, oldest first, and then the
+// pending events that expired.
+func (a *Aggregator) flush(expired func(*event) bool) error {
+	a.mu.Lock()
+	flushed := a.retry
+	a.retry = nil
+	for stream, e := range a.pending {
+		if expired(e) {
+			flushed = append(flushed, e)
+			delete(a.pending, stream)
+		}
+	}
+	a.mu.Unlock()
+
+	var errs []error
+	for _, e := range flushed {
+		if err := a.emitEvent(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Start flushes expired events in the background until Close.
+func (a *Aggregator) Start() {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(a.flushTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-a.done:
+				return
+			case <-ticker.C:
+				a.FlushExpired()
+			}
+		}
+	}()
+}
+
+// Close stops the background flush and emits what is still pending. Events
+// that still cannot be emitted are dropped and counted by Dropped.
+func (a *Aggregator) Close() {
+	close(a.done)
+	a.wg.Wait()
+	a.Flush()
+
+	a.mu.Lock()
+	a.dropped.Add(int64(len(a.retry)))
+	a.retry = nil
+	a.mu.Unlock()
+}
@@ -0,0 +1,222 @@
+package multiline
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// collector records emitted messages and fails while err is set.
+type collector struct {
+	mu       sync.Mutex
+	messages []string
+	err      error
+}
+
+func (c *collector) emit(_ string, logs types.LogFormat, _ interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.messages = append(c.messages, logs.Message)
+	return nil
+}
+
+func (c *collector) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+func (c *collector) emitted() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.messages...)
+}
+
+func newTestAggregator(t *testing.T, cfg config.MultilineConfig, c *collector) *Aggregator {
+	t.Helper()
+	a, err := New(cfg, c.emit)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return a
+}
+
+func addLines(t *testing.T, a *Aggregator, stream string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if err := a.Add(stream, types.LogFormat{Message: line}, nil); err != nil {
+			t.Fatalf("Add(%q): %v", line, err)
+		}
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	lines := []string{
+		"2024-01-01 panic: boom",
+		"goroutine 1 [running]:",
+		"\tmain.go:10",
+		"2024-01-01 recovered",
+		"2024-01-01 done",
+		"\tcaller.go:3",
+	}
+	want := []string{
+		"2024-01-01 panic: boom\ngoroutine 1 [running]:\n\tmain.go:10",
+		"2024-01-01 recovered",
+		"2024-01-01 done\n\tcaller.go:3",
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.MultilineConfig
+		want []string
+	}{
+		{"start pattern", config.MultilineConfig{Start: `^\d{4}-`}, want},
+		{"continuation pattern", config.MultilineConfig{Continuation: `^(\s|goroutine )`}, want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &collector{}
+			a := newTestAggregator(t, tt.cfg, c)
+			addLines(t, a, "s", lines...)
+			a.Flush()
+			if got := c.emitted(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("emitted %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamsAreSeparate(t *testing.T) {
+	c := &collector{}
+	a := newTestAggregator(t, config.MultilineConfig{Continuation: `^\s`}, c)
+	addLines(t, a, "a", "a1")
+	addLines(t, a, "b", "b1")
+	addLines(t, a, "a", " a2")
+	addLines(t, a, "b", " b2")
+	a.Flush()
+
+	got := c.emitted()
+	if len(got) != 2 || !(got[0] == "a1\n a2" || got[1] == "a1\n a2") {
+		t.Errorf("emitted %q, want one event per stream", got)
+	}
+}
+
+func TestMaxLines(t *testing.T) {
+	c := &collector{}
+	a := newTestAggregator(t, config.MultilineConfig{Continuation: `^\s`, MaxLines: 3}, c)
+	addLines(t, a, "s", "first", " 2", " 3", " 4")
+
+	if got, want := c.emitted(), []string{"first\n 2\n 3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("emitted %q, want %q", got, want)
+	}
+	a.Flush()
+	if got := c.emitted(); len(got) != 2 || got[1] != " 4" {
+		t.Errorf("emitted %q, want the line after MaxLines as its own event", got)
+	}
+}
+
+func TestFlushTimeout(t *testing.T) {
+	c := &collector{}
+	a := newTestAggregator(t, config.MultilineConfig{
+		Continuation: `^\s`,
+		FlushTimeout: config.Duration(20 * time.Millisecond),
+	}, c)
+	addLines(t, a, "s", "first", " second")
+
+	a.FlushExpired()
+	if got := c.emitted(); len(got) != 0 {
+		t.Fatalf("emitted %q before the flush timeout", got)
+	}
+
+	a.Start()
+	defer a.Close()
+	deadline := time.Now().Add(time.Second)
+	for len(c.emitted()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got, want := c.emitted(), []string{"first\n second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("emitted %q, want %q", got, want)
+	}
+}
+
+func TestClose(t *testing.T) {
+	c := &collector{}
+	a := newTestAggregator(t, config.MultilineConfig{Continuation: `^\s`}, c)
+	a.Start()
+	addLines(t, a, "a", "a1", " a2")
+	addLines(t, a, "b", "b1")
+	a.Close()
+
+	if got := c.emitted(); len(got) != 2 {
+		t.Errorf("emitted %q, want both pending events", got)
+	}
+}
+
+func TestFailedEmitIsRetried(t *testing.T) {
+	c := &collector{err: errors.New("queue down")}
+	a := newTestAggregator(t, config.MultilineConfig{Continuation: `^\s`}, c)
+
+	// Completing the first event fails, yet the line that started the next
+	// one is taken.
+	addLines(t, a, "s", "first", " more", "second")
+	if a.Failed() != 1 || a.Retrying() != 1 {
+		t.Fatalf("failed = %d, retrying = %d, want 1, 1", a.Failed(), a.Retrying())
+	}
+
+	c.setErr(nil)
+	a.FlushExpired()
+	if got, want := c.emitted(), []string{"first\n more"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("emitted %q, want %q", got, want)
+	}
+	if a.Retrying() != 0 {
+		t.Errorf("retrying = %d after a successful flush", a.Retrying())
+	}
+
+	a.Close()
+	if got, want := c.emitted(), []string{"first\n more", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("emitted %q, want %q", got, want)
+	}
+}
+
+func TestBacklogFull(t *testing.T) {
+	c := &collector{err: errors.New("queue down")}
+	a := newTestAggregator(t, config.MultilineConfig{Start: `^start`}, c)
+	for i := 0; i <= maxRetryEvents; i++ {
+		addLines(t, a, "s", "start")
+	}
+
+	if err := a.Add("s", types.LogFormat{Message: "start"}, nil); !errors.Is(err, ErrBacklogFull) {
+		t.Fatalf("Add error = %v, want ErrBacklogFull", err)
+	}
+
+	a.Close()
+	if got := a.Dropped(); got != maxRetryEvents+1 {
+		t.Errorf("dropped = %d, want %d", got, maxRetryEvents+1)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.MultilineConfig
+	}{
+		{"no pattern", config.MultilineConfig{}},
+		{"invalid start", config.MultilineConfig{Start: "("}},
+		{"invalid continuation", config.MultilineConfig{Continuation: "("}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg, (&collector{}).emit); err == nil {
+				t.Error("New succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// combinedLog matches the nginx/Apache combined format, and the common
+// format when the referer and user agent are missing.
+var combinedLog = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "([^"]*)" (\d{3}) (\d+|-)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+func parseCombined(line string) (types.LogFormat, error) {
+	m := combinedLog.FindStringSubmatch(line)
+	if m == nil {
+		return types.LogFormat{}, errors.New("line is not in combined log format")
+	}
+
+	ts, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[4])
+	if err != nil {
+		return types.LogFormat{}, err
+	}
+	status, _ := strconv.Atoi(m[6])
+
+	fields := map[string]interface{}{
+		"client_ip": m[1],
+		"status":    status,
+	}
+	if m[3] != "-" {
+		fields["user"] = m[3]
+	}
+	if m[7] != "-" {
+		size, _ := strconv.ParseInt(m[7], 10, 64)
+		fields["bytes"] = size
+	}
+	if request := strings.SplitN(m[5], " ", 3); len(request) == 3 {
+		fields["method"] = request[0]
+		fields["path"] = request[1]
+		fields["protocol"] = request[2]
+	} else {
+		fields["request"] = m[5]
+	}
+	if m[8] != "" && m[8] != "-" {
+		fields["referer"] = m[8]
+	}
+	if m[9] != "" && m[9] != "-" {
+		fields["user_agent"] = m[9]
+	}
+
+	level := "info"
+	switch {
+	case status >= 500:
+		level = "error"
+	case status >= 400:
+		level = "warn"
+	}
+
+	return types.LogFormat{
+		Timestamp: ts.UTC(),
+		Level:     level,
+		Message:   line,
+		Fields:    fields,
+	}, nil
+}
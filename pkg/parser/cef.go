@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+var cefHeaderFields = []string{
+	"cef_version",
+	"device_vendor",
+	"device_product",
+	"device_version",
+	"signature_id",
+	"name",
+	"severity",
+}
+
+var cefExtensionKey = regexp.MustCompile(`(?:^|\s)([A-Za-z0-9_.\[\]-]+)=`)
+
+// parseCEF parses ArcSight Common Event Format events, optionally preceded
+// by a syslog header:
+// CEF:0|Vendor|Product|1.0|100|Login failed|7|src=10.0.0.1 suser=bob
+func parseCEF(line string) (types.LogFormat, error) {
+	start := strings.Index(line, "CEF:")
+	if start < 0 {
+		return types.LogFormat{}, errors.New("line is not a CEF event")
+	}
+
+	header, extension, err := splitCEFHeader(line[start+len("CEF:"):])
+	if err != nil {
+		return types.LogFormat{}, err
+	}
+
+	fields := map[string]interface{}{}
+	for i, name := range cefHeaderFields {
+		fields[name] = header[i]
+	}
+	extensions := parseCEFExtension(extension)
+	for key, value := range extensions {
+		fields[key] = typedValue(value)
+	}
+
+	logs := types.LogFormat{
+		Timestamp: time.Now().UTC(),
+		Level:     cefLevel(header[6]),
+		Message:   header[5],
+		Fields:    fields,
+	}
+	if rt, ok := extensions["rt"]; ok {
+		if ts, err := parseTime(rt); err == nil {
+			logs.Timestamp = ts
+		}
+	}
+	return logs, nil
+}
+
+// splitCEFHeader splits the seven pipe separated header fields, honoring
+// escaped pipes, and returns the remaining extension.
+func splitCEFHeader(s string) ([]string, string, error) {
+	var header []string
+	var current strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && (s[i+1] == '|' || s[i+1] == '\\') {
+			current.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		if c == '|' {
+			header = append(header, current.String())
+			current.Reset()
+			if len(header) == len(cefHeaderFields) {
+				return header, s[i+1:], nil
+			}
+			continue
+		}
+		current.WriteByte(c)
+	}
+	if len(header) == len(cefHeaderFields)-1 {
+		// event without extension
+		return append(header, current.String()), "", nil
+	}
+	return nil, "", errors.New("incomplete CEF header")
+}
+
+func parseCEFExtension(s string) map[string]string {
+	values := map[string]string{}
+	matches := cefExtensionKey.FindAllStringSubmatchIndex(s, -1)
+	for i, m := range matches {
+		// skip keys that are really an escaped '=' inside a value
+		if m[2] > 0 && s[m[2]-1] == '\\' {
+			continue
+		}
+		end := len(s)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		key := s[m[2]:m[3]]
+		value := strings.TrimSpace(s[m[1]:end])
+		value = strings.NewReplacer(`\=`, "=", `\\`, `\`, `\n`, "\n", `\r`, "\r").Replace(value)
+		values[key] = value
+	}
+	return values
+}
+
+// cefLevel maps the 0-10 or Low/Medium/High/Very-High severity to a level.
+func cefLevel(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "low":
+		return "info"
+	case "medium":
+		return "warn"
+	case "high":
+		return "error"
+	case "very-high":
+		return "critical"
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(severity))
+	switch {
+	case err != nil:
+		return "info"
+	case n >= 9:
+		return "critical"
+	case n >= 7:
+		return "error"
+	case n >= 4:
+		return "warn"
+	default:
+		return "info"
+	}
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// parseCRI parses Kubernetes container runtime log lines:
+// 2024-01-02T15:04:05.123456789Z stdout F the message
+// A "P" tag marks a partial line that continues in the next record.
+func parseCRI(line string) (types.LogFormat, error) {
+	parts := strings.SplitN(line, " ", 4)
+	if len(parts) < 3 {
+		return types.LogFormat{}, errors.New("line is not in CRI format")
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return types.LogFormat{}, err
+	}
+	stream := parts[1]
+	if stream != "stdout" && stream != "stderr" {
+		return types.LogFormat{}, errors.New("invalid CRI stream")
+	}
+	tag := parts[2]
+
+	message := ""
+	if len(parts) == 4 {
+		message = parts[3]
+	}
+
+	fields := map[string]interface{}{
+		"stream": stream,
+	}
+	if strings.Split(tag, ":")[0] == "P" {
+		fields["partial"] = true
+	}
+
+	return types.LogFormat{
+		Timestamp: ts.UTC(),
+		Level:     "info",
+		Message:   message,
+		Fields:    fields,
+	}, nil
+}
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// parseLogfmt parses key=value pairs, e.g.
+// ts=2024-01-02T15:04:05Z level=info msg="request done" latency_ms=12
+func parseLogfmt(line string) (types.LogFormat, error) {
+	pairs, err := splitLogfmt(line)
+	if err != nil {
+		return types.LogFormat{}, err
+	}
+	if len(pairs) == 0 {
+		return types.LogFormat{}, errors.New("no logfmt pairs found")
+	}
+
+	logs := types.LogFormat{
+		Level:  "info",
+		Fields: map[string]interface{}{},
+	}
+	for _, kv := range pairs {
+		key, value := kv[0], kv[1]
+		switch strings.ToLower(key) {
+		case "ts", "time", "timestamp", "t":
+			if ts, err := parseTime(value); err == nil {
+				logs.Timestamp = ts
+				continue
+			}
+		case "level", "lvl", "severity":
+			logs.Level = strings.ToLower(value)
+			continue
+		case "msg", "message":
+			logs.Message = value
+			continue
+		}
+		logs.Fields[key] = typedValue(value)
+	}
+
+	if logs.Message == "" {
+		logs.Message = line
+	}
+	if logs.Timestamp.IsZero() {
+		logs.Timestamp = time.Now().UTC()
+	}
+	return logs, nil
+}
+
+// splitLogfmt returns the key/value pairs of a logfmt line. Keys without a
+// value get "true".
+func splitLogfmt(line string) ([][2]string, error) {
+	var pairs [][2]string
+	i := 0
+	for i < len(line) {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, errors.New("empty logfmt key")
+		}
+		if i >= len(line) || line[i] == ' ' {
+			pairs = append(pairs, [2]string{key, "true"})
+			continue
+		}
+		i++ // skip '='
+
+		var value strings.Builder
+		if i < len(line) && line[i] == '"' {
+			i++
+			closed := false
+			for i < len(line) {
+				c := line[i]
+				if c == '\\' && i+1 < len(line) {
+					value.WriteByte(line[i+1])
+					i += 2
+					continue
+				}
+				if c == '"' {
+					closed = true
+					i++
+					break
+				}
+				value.WriteByte(c)
+				i++
+			}
+			if !closed {
+				return nil, errors.New("unterminated quoted logfmt value")
+			}
+		} else {
+			start := i
+			for i < len(line) && line[i] != ' ' {
+				i++
+			}
+			value.WriteString(line[start:i])
+		}
+		pairs = append(pairs, [2]string{key, value.String()})
+	}
+	return pairs, nil
+}
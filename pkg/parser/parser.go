@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// Parser turns one raw line into a log.
+type Parser interface {
+	Parse(line string) (types.LogFormat, error)
+}
+
+// ParserFunc adapts a function to the Parser interface.
+type ParserFunc func(line string) (types.LogFormat, error)
+
+func (f ParserFunc) Parse(line string) (types.LogFormat, error) {
+	return f(line)
+}
+
+var parsers = map[string]Parser{
+	"json":     ParserFunc(parseJSON),
+	"raw":      ParserFunc(parseRaw),
+	"logfmt":   ParserFunc(parseLogfmt),
+	"combined": ParserFunc(parseCombined),
+	"nginx":    ParserFunc(parseCombined),
+	"apache":   ParserFunc(parseCombined),
+	"cri":      ParserFunc(parseCRI),
+	"cef":      ParserFunc(parseCEF),
+}
+
+// Get returns the parser registered under name. An empty name is "json".
+func Get(name string) (Parser, error) {
+	if name == "" {
+		name = "json"
+	}
+	p, ok := parsers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", name)
+	}
+	return p, nil
+}
+
+func parseJSON(line string) (types.LogFormat, error) {
+	var logs types.LogFormat
+	if err := json.Unmarshal([]byte(line), &logs); err != nil {
+		return logs, err
+	}
+	if logs.Message == "" {
+		return logs, errors.New("log has no message")
+	}
+	return logs, nil
+}
+
+func parseRaw(line string) (types.LogFormat, error) {
+	return types.LogFormat{
+		Timestamp: time.Now().UTC(),
+		Level:     "info",
+		Message:   line,
+	}, nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	"Jan _2 2006 15:04:05",
+	time.Stamp,
+}
+
+// parseTime accepts the common textual layouts and unix epochs in seconds or
+// milliseconds.
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if ts, err := time.Parse(layout, s); err == nil {
+			if ts.Year() == 0 {
+				ts = ts.AddDate(time.Now().Year(), 0, 0)
+			}
+			return ts.UTC(), nil
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if f > 1e12 {
+			return time.UnixMilli(int64(f)).UTC(), nil
+		}
+		return time.Unix(0, int64(f*float64(time.Second))).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
+
+// typedValue converts numeric and boolean strings so they are indexed as such.
+func typedValue(s string) interface{} {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil && (s == "true" || s == "false") {
+		return b
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+type condition struct {
+	field   string
+	equals  *string
+	matches *regexp.Regexp
+	exists  *bool
+}
+
+func newCondition(cfg *config.Condition) (*condition, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.Field == "" {
+		return nil, fmt.Errorf("condition requires a field")
+	}
+
+	c := &condition{
+		field:  cfg.Field,
+		equals: cfg.Equals,
+		exists: cfg.Exists,
+	}
+	if cfg.Matches != "" {
+		re, err := regexp.Compile(cfg.Matches)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition pattern: %w", err)
+		}
+		c.matches = re
+	}
+	return c, nil
+}
+
+// match reports whether logs satisfies the condition. A nil condition always
+// matches.
+func (c *condition) match(logs *types.LogFormat) bool {
+	if c == nil {
+		return true
+	}
+
+	value, ok := logs.GetString(c.field)
+	if c.exists != nil && *c.exists != ok {
+		return false
+	}
+	if c.equals != nil && (!ok || value != *c.equals) {
+		return false
+	}
+	if c.matches != nil && (!ok || !c.matches.MatchString(value)) {
+		return false
+	}
+	return true
+}
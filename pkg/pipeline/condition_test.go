@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+func TestCondition(t *testing.T) {
+	yes, no := true, false
+	errorLevel := "error"
+	logs := types.LogFormat{Level: "error", Fields: map[string]interface{}{"service": "checkout-api", "status": 503}}
+
+	tests := []struct {
+		name      string
+		condition *config.Condition
+		want      bool
+	}{
+		{"nil always matches", nil, true},
+		{"equals", &config.Condition{Field: "level", Equals: &errorLevel}, true},
+		{"equals other value", &config.Condition{Field: "service", Equals: &errorLevel}, false},
+		{"equals missing field", &config.Condition{Field: "host", Equals: &errorLevel}, false},
+		{"matches", &config.Condition{Field: "service", Matches: "-api$"}, true},
+		{"matches non-string value", &config.Condition{Field: "status", Matches: "^5"}, true},
+		{"does not match", &config.Condition{Field: "service", Matches: "^web"}, false},
+		{"exists", &config.Condition{Field: "service", Exists: &yes}, true},
+		{"exists missing", &config.Condition{Field: "host", Exists: &yes}, false},
+		{"not exists", &config.Condition{Field: "host", Exists: &no}, true},
+		{"every option must match", &config.Condition{Field: "level", Equals: &errorLevel, Matches: "^warn"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newCondition(tt.condition)
+			if err != nil {
+				t.Fatalf("newCondition: %v", err)
+			}
+			if got := c.match(&logs); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionErrors(t *testing.T) {
+	for _, cfg := range []*config.Condition{{}, {Field: "level", Matches: "("}} {
+		if _, err := newCondition(cfg); err == nil {
+			t.Errorf("newCondition(%+v) succeeded, want error", cfg)
+		}
+	}
+}
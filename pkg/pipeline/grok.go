@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// grokPatterns is a small built-in subset of the usual grok library.
+var grokPatterns = map[string]string{
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"INT":               `[+-]?\d+`,
+	"POSINT":            `\b[1-9]\d*\b`,
+	"NUMBER":            `[+-]?(?:\d+(?:\.\d*)?|\.\d+)`,
+	"BASE16NUM":         `(?:0[xX])?[0-9A-Fa-f]+`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:\d{1,3}\.){3}\d{1,3}`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"USER":              `[a-zA-Z0-9._-]+`,
+	"PATH":              `(?:/[^\s]*)+`,
+	"URIPATHPARAM":      `/[^\s?#]*(?:\?[^\s#]*)?`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"LOGLEVEL":          `(?i:trace|debug|info|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|alert)`,
+	"TIMESTAMP_ISO8601": `\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(?::\d{2}(?:[.,]\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?`,
+	"HTTPDATE":          `\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`,
+}
+
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([\w.@-]+))?(?::(int|float))?\}`)
+
+type grokCapture struct {
+	field      string
+	conversion string
+}
+
+// grokProcessor extracts fields with a grok expression or a plain regular
+// expression with named groups.
+type grokProcessor struct {
+	field    string
+	re       *regexp.Regexp
+	captures map[string]grokCapture
+}
+
+func newGrokProcessor(pc config.ProcessorConfig) (*grokProcessor, error) {
+	if pc.Pattern == "" {
+		return nil, errors.New("grok requires a pattern")
+	}
+	field := pc.Field
+	if field == "" {
+		field = "message"
+	}
+
+	p := &grokProcessor{
+		field:    field,
+		captures: map[string]grokCapture{},
+	}
+
+	expr, err := p.expand(pc.Pattern, 0)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grok pattern: %w", err)
+	}
+	p.re = re
+
+	// plain named groups capture into the field of the same name
+	for _, name := range re.SubexpNames() {
+		if _, ok := p.captures[name]; name != "" && !ok {
+			p.captures[name] = grokCapture{field: name}
+		}
+	}
+	return p, nil
+}
+
+// expand replaces %{NAME:field:type} references with regular expressions.
+func (p *grokProcessor) expand(pattern string, depth int) (string, error) {
+	if depth > 10 {
+		return "", errors.New("grok pattern nesting too deep")
+	}
+
+	var expandErr error
+	expanded := grokReference.ReplaceAllStringFunc(pattern, func(ref string) string {
+		parts := grokReference.FindStringSubmatch(ref)
+		name, field, conversion := parts[1], parts[2], parts[3]
+
+		def, ok := grokPatterns[name]
+		if !ok {
+			expandErr = fmt.Errorf("unknown grok pattern %q", name)
+			return ""
+		}
+		inner, err := p.expand(def, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+		if field == "" {
+			return "(?:" + inner + ")"
+		}
+
+		group := fmt.Sprintf("grok%d", len(p.captures))
+		p.captures[group] = grokCapture{field: field, conversion: conversion}
+		return fmt.Sprintf("(?P<%s>%s)", group, inner)
+	})
+	return expanded, expandErr
+}
+
+func (p *grokProcessor) Process(logs *types.LogFormat) (bool, error) {
+	value, ok := logs.GetString(p.field)
+	if !ok {
+		return true, nil
+	}
+
+	match := p.re.FindStringSubmatch(value)
+	if match == nil {
+		return true, fmt.Errorf("%v does not match pattern", p.field)
+	}
+
+	var errs []error
+	for i, name := range p.re.SubexpNames() {
+		capture, ok := p.captures[name]
+		if !ok || match[i] == "" {
+			continue
+		}
+		var v interface{} = strings.Trim(match[i], `"`)
+		switch capture.conversion {
+		case "int":
+			n, err := strconv.ParseInt(match[i], 10, 64)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			v = n
+		case "float":
+			f, err := strconv.ParseFloat(match[i], 64)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			v = f
+		}
+		if err := logs.SetField(capture.field, v); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return true, errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+func TestGrokProcessor(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.ProcessorConfig
+		in      types.LogFormat
+		want    map[string]interface{}
+		level   string
+		wantErr bool
+	}{
+		{
+			name:   "named patterns with conversions",
+			config: config.ProcessorConfig{Pattern: `%{IP:client} %{WORD:method} %{URIPATHPARAM:path} %{INT:status:int} %{NUMBER:took:float}`},
+			in:     types.LogFormat{Message: "10.0.0.1 GET /orders?id=7 200 0.25"},
+			want: map[string]interface{}{
+				"client": "10.0.0.1",
+				"method": "GET",
+				"path":   "/orders?id=7",
+				"status": int64(200),
+				"took":   0.25,
+			},
+		},
+		{
+			name:   "top-level attribute and quoted string",
+			config: config.ProcessorConfig{Pattern: `%{LOGLEVEL:level} %{QUOTEDSTRING:user}`},
+			in:     types.LogFormat{Message: `ERROR "ana lopez"`},
+			want:   map[string]interface{}{"user": "ana lopez"},
+			level:  "ERROR",
+		},
+		{
+			name:   "plain named groups",
+			config: config.ProcessorConfig{Field: "line", Pattern: `^(?P<host>\S+) (?P<rest>.*)$`},
+			in:     types.LogFormat{Fields: map[string]interface{}{"line": "web-1 started"}},
+			want:   map[string]interface{}{"line": "web-1 started", "host": "web-1", "rest": "started"},
+		},
+		{
+			name:    "no match",
+			config:  config.ProcessorConfig{Pattern: `^%{INT:n}$`},
+			in:      types.LogFormat{Message: "abc"},
+			wantErr: true,
+		},
+		{
+			name:   "missing field is skipped",
+			config: config.ProcessorConfig{Field: "line", Pattern: `%{INT:n}`},
+			in:     types.LogFormat{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newGrokProcessor(tt.config)
+			if err != nil {
+				t.Fatalf("newGrokProcessor: %v", err)
+			}
+			logs := tt.in
+			keep, err := p.Process(&logs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Process error = %v, want error %v", err, tt.wantErr)
+			}
+			if !keep {
+				t.Fatal("grok dropped the log")
+			}
+			if !reflect.DeepEqual(logs.Fields, tt.want) {
+				t.Errorf("Fields = %#v, want %#v", logs.Fields, tt.want)
+			}
+			if logs.Level != tt.level {
+				t.Errorf("Level = %q, want %q", logs.Level, tt.level)
+			}
+		})
+	}
+}
+
+func TestGrokNestingLimit(t *testing.T) {
+	grokPatterns["LOOP"] = "%{LOOP}"
+	defer delete(grokPatterns, "LOOP")
+	if _, err := newGrokProcessor(config.ProcessorConfig{Pattern: "%{LOOP:x}"}); err == nil {
+		t.Fatal("recursive pattern accepted")
+	}
+}
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// maxRouteDepth guards against routes that loop between pipelines.
+const maxRouteDepth = 8
+
+// Processor transforms a log in place. Returning false drops the log.
+type Processor interface {
+	Process(logs *types.LogFormat) (bool, error)
+}
+
+type step struct {
+	kind      string
+	condition *condition
+	processor Processor
+}
+
+type Pipeline struct {
+	name  string
+	steps []step
+}
+
+// Manager holds every configured pipeline and the source to pipeline mapping.
+type Manager struct {
+	pipelines map[string]*Pipeline
+	sources   map[string]string
+}
+
+func NewManager(cfg *config.File) (*Manager, error) {
+	m := &Manager{
+		pipelines: map[string]*Pipeline{},
+		sources:   map[string]string{},
+	}
+
+	// create every pipeline first so routes can refer to any of them
+	for name := range cfg.Pipelines {
+		m.pipelines[name] = &Pipeline{name: name}
+	}
+
+	for name, processors := range cfg.Pipelines {
+		for i, pc := range processors {
+			processor, err := m.newProcessor(pc)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline %v processor %d: %w", name, i, err)
+			}
+			cond, err := newCondition(pc.If)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline %v processor %d: %w", name, i, err)
+			}
+			m.pipelines[name].steps = append(m.pipelines[name].steps, step{
+				kind:      pc.Type,
+				condition: cond,
+				processor: processor,
+			})
+		}
+	}
+
+	for source, sc := range cfg.Sources {
+		if sc.Pipeline == "" {
+			continue
+		}
+		if _, ok := m.pipelines[sc.Pipeline]; !ok {
+			return nil, fmt.Errorf("source %v refers to unknown pipeline %v", source, sc.Pipeline)
+		}
+		m.sources[source] = sc.Pipeline
+	}
+
+	return m, nil
+}
+
+func (m *Manager) newProcessor(pc config.ProcessorConfig) (Processor, error) {
+	switch pc.Type {
+	case "json":
+		return newJSONProcessor(pc), nil
+	case "grok":
+		return newGrokProcessor(pc)
+	case "rename":
+		return newRenameProcessor(pc)
+	case "remove":
+		return newRemoveProcessor(pc)
+	case "set":
+		return newSetProcessor(pc)
+	case "level":
+		return newLevelProcessor(pc), nil
+	case "drop":
+		return dropProcessor{}, nil
+	case "route":
+		return newRouteProcessor(m, pc)
+	default:
+		return nil, fmt.Errorf("unknown processor type %q", pc.Type)
+	}
+}
+
+// Process runs the pipeline configured for source. Sources without a
+// pipeline pass through unchanged.
+func (m *Manager) Process(source string, logs *types.LogFormat) bool {
+	name, ok := m.sources[source]
+	if !ok {
+		return true
+	}
+	return m.pipelines[name].run(logs, 0)
+}
+
+// run applies each step in order. A failing step is recorded on the log in
+// the pipeline_error field and processing continues with the next step.
+func (p *Pipeline) run(logs *types.LogFormat, depth int) bool {
+	for _, s := range p.steps {
+		if !s.condition.match(logs) {
+			continue
+		}
+
+		if route, ok := s.processor.(*routeProcessor); ok {
+			if keep, routed := route.route(logs, depth); routed {
+				return keep
+			}
+			continue
+		}
+
+		keep, err := s.processor.Process(logs)
+		if err != nil {
+			log.Printf("Pipeline %v %v processor failed. Error: %v", p.name, s.kind, err)
+			logs.SetField("pipeline_error", fmt.Sprintf("%s: %v", s.kind, err))
+		}
+		if !keep {
+			return false
+		}
+	}
+	return true
+}
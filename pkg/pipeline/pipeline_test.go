@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+func newTestManager(t *testing.T, pipelines map[string][]config.ProcessorConfig) *Manager {
+	t.Helper()
+	m, err := NewManager(&config.File{
+		Pipelines: pipelines,
+		Sources:   map[string]config.SourceConfig{"app": {Pipeline: "main"}},
+	})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m
+}
+
+func TestRoute(t *testing.T) {
+	nginx := "nginx"
+	m := newTestManager(t, map[string][]config.ProcessorConfig{
+		"main": {
+			{Type: "route", Routes: []config.RouteConfig{
+				{If: &config.Condition{Field: "service", Equals: &nginx}, Pipeline: "access"},
+				{If: &config.Condition{Field: "level", Matches: "^debug$"}, Pipeline: "discard"},
+			}},
+			{Type: "set", Field: "routed", Value: false},
+		},
+		"access":  {{Type: "set", Field: "routed", Value: true}},
+		"discard": {{Type: "drop"}},
+	})
+
+	tests := []struct {
+		name   string
+		in     types.LogFormat
+		keep   bool
+		routed interface{}
+	}{
+		{"first matching route", types.LogFormat{Fields: map[string]interface{}{"service": "nginx"}}, true, true},
+		{"route that drops", types.LogFormat{Level: "debug"}, false, nil},
+		{"no route continues the pipeline", types.LogFormat{Level: "info"}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := tt.in
+			if keep := m.Process("app", &logs); keep != tt.keep {
+				t.Fatalf("Process keep = %v, want %v", keep, tt.keep)
+			}
+			if got := logs.Fields["routed"]; got != tt.routed {
+				t.Errorf("routed = %v, want %v", got, tt.routed)
+			}
+		})
+	}
+}
+
+func TestRouteLoop(t *testing.T) {
+	m := newTestManager(t, map[string][]config.ProcessorConfig{
+		"main": {{Type: "route", Routes: []config.RouteConfig{{Pipeline: "main"}}}},
+	})
+	logs := types.LogFormat{Message: "loop"}
+	if !m.Process("app", &logs) {
+		t.Fatal("looping route dropped the log")
+	}
+}
+
+func TestPipelineConditionsAndErrors(t *testing.T) {
+	errorLevel := "error"
+	m := newTestManager(t, map[string][]config.ProcessorConfig{
+		"main": {
+			{Type: "json"},
+			{Type: "set", Field: "alert", Value: true, If: &config.Condition{Field: "level", Equals: &errorLevel}},
+			{Type: "level"},
+		},
+	})
+
+	logs := types.LogFormat{Message: `{"level": "ERROR"`}
+	if !m.Process("app", &logs) {
+		t.Fatal("log dropped")
+	}
+	if _, ok := logs.Fields["pipeline_error"]; !ok {
+		t.Error("failed step not recorded in pipeline_error")
+	}
+	if logs.Level != "" {
+		t.Errorf("Level = %q, want empty", logs.Level)
+	}
+
+	logs = types.LogFormat{Message: `{"level": "error", "message": "boom"}`}
+	m.Process("app", &logs)
+	if logs.Fields["alert"] != true || logs.Level != "error" {
+		t.Errorf("conditional step not applied: %+v", logs)
+	}
+
+	logs = types.LogFormat{Message: "untouched"}
+	if !m.Process("other", &logs) || logs.Fields != nil {
+		t.Errorf("source without pipeline changed: %+v", logs)
+	}
+}
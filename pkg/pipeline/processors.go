@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// jsonProcessor parses a JSON object embedded in a field (message by
+// default). Keys named timestamp, level and message replace the top-level
+// attributes, everything else becomes a structured field, optionally nested
+// under Target.
+type jsonProcessor struct {
+	field  string
+	target string
+}
+
+func newJSONProcessor(pc config.ProcessorConfig) *jsonProcessor {
+	field := pc.Field
+	if field == "" {
+		field = "message"
+	}
+	return &jsonProcessor{field: field, target: pc.Target}
+}
+
+func (p *jsonProcessor) Process(logs *types.LogFormat) (bool, error) {
+	value, ok := logs.GetString(p.field)
+	if !ok {
+		return true, nil
+	}
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "{") {
+		return true, nil
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return true, fmt.Errorf("cannot parse %v as json: %w", p.field, err)
+	}
+
+	if p.target != "" {
+		return true, logs.SetField(p.target, parsed)
+	}
+
+	if _, ok := parsed["message"]; !ok && p.field == "message" {
+		// keep the original text searchable when it has no message key
+		parsed["message"] = value
+	}
+	var errs []error
+	for key, v := range parsed {
+		if err := logs.SetField(key, v); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return true, errors.Join(errs...)
+}
+
+type renameProcessor struct {
+	from string
+	to   string
+}
+
+func newRenameProcessor(pc config.ProcessorConfig) (*renameProcessor, error) {
+	if pc.From == "" || pc.To == "" {
+		return nil, errors.New("rename requires from and to")
+	}
+	return &renameProcessor{from: pc.From, to: pc.To}, nil
+}
+
+func (p *renameProcessor) Process(logs *types.LogFormat) (bool, error) {
+	value, ok := logs.GetField(p.from)
+	if !ok {
+		return true, nil
+	}
+	if err := logs.SetField(p.to, value); err != nil {
+		return true, err
+	}
+	logs.DeleteField(p.from)
+	return true, nil
+}
+
+type removeProcessor struct {
+	fields []string
+}
+
+func newRemoveProcessor(pc config.ProcessorConfig) (*removeProcessor, error) {
+	fields := pc.Fields
+	if pc.Field != "" {
+		fields = append(fields, pc.Field)
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("remove requires field or fields")
+	}
+	return &removeProcessor{fields: fields}, nil
+}
+
+func (p *removeProcessor) Process(logs *types.LogFormat) (bool, error) {
+	for _, field := range p.fields {
+		logs.DeleteField(field)
+	}
+	return true, nil
+}
+
+type setProcessor struct {
+	values map[string]interface{}
+}
+
+func newSetProcessor(pc config.ProcessorConfig) (*setProcessor, error) {
+	values := map[string]interface{}{}
+	for k, v := range pc.Values {
+		values[k] = v
+	}
+	if pc.Field != "" {
+		values[pc.Field] = pc.Value
+	}
+	if len(values) == 0 {
+		return nil, errors.New("set requires field and value, or values")
+	}
+	return &setProcessor{values: values}, nil
+}
+
+func (p *setProcessor) Process(logs *types.LogFormat) (bool, error) {
+	var errs []error
+	for field, value := range p.values {
+		if err := logs.SetField(field, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return true, errors.Join(errs...)
+}
+
+var defaultLevels = map[string]string{
+	"trace":       "debug",
+	"debug":       "debug",
+	"dbg":         "debug",
+	"info":        "info",
+	"inf":         "info",
+	"information": "info",
+	"notice":      "notice",
+	"warn":        "warn",
+	"warning":     "warn",
+	"wrn":         "warn",
+	"error":       "error",
+	"err":         "error",
+	"eror":        "error",
+	"critical":    "critical",
+	"crit":        "critical",
+	"fatal":       "critical",
+	"panic":       "critical",
+	"alert":       "alert",
+	"emerg":       "emergency",
+	"emergency":   "emergency",
+}
+
+// levelProcessor lower-cases the level and maps common aliases, plus any
+// custom Mapping, onto a fixed set of names.
+type levelProcessor struct {
+	field   string
+	mapping map[string]string
+}
+
+func newLevelProcessor(pc config.ProcessorConfig) *levelProcessor {
+	field := pc.Field
+	if field == "" {
+		field = "level"
+	}
+	mapping := map[string]string{}
+	for k, v := range defaultLevels {
+		mapping[k] = v
+	}
+	for k, v := range pc.Mapping {
+		mapping[strings.ToLower(k)] = v
+	}
+	return &levelProcessor{field: field, mapping: mapping}
+}
+
+func (p *levelProcessor) Process(logs *types.LogFormat) (bool, error) {
+	value, ok := logs.GetString(p.field)
+	if !ok {
+		return true, nil
+	}
+	level := strings.ToLower(strings.TrimSpace(value))
+	if mapped, ok := p.mapping[level]; ok {
+		level = mapped
+	}
+	return true, logs.SetField("level", level)
+}
+
+// dropProcessor discards the log; combine it with an if condition.
+type dropProcessor struct{}
+
+func (dropProcessor) Process(logs *types.LogFormat) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+func TestProcessors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.ProcessorConfig
+		in      types.LogFormat
+		want    types.LogFormat
+		dropped bool
+		wantErr bool
+	}{
+		{
+			name:   "json into fields",
+			config: config.ProcessorConfig{Type: "json"},
+			in:     types.LogFormat{Message: `{"level": "warn", "message": "slow", "latency_ms": 812}`},
+			want:   types.LogFormat{Level: "warn", Message: "slow", Fields: map[string]interface{}{"latency_ms": float64(812)}},
+		},
+		{
+			name:   "json keeps message without message key",
+			config: config.ProcessorConfig{Type: "json"},
+			in:     types.LogFormat{Message: `{"user": "ana"}`},
+			want:   types.LogFormat{Message: `{"user": "ana"}`, Fields: map[string]interface{}{"user": "ana"}},
+		},
+		{
+			name:   "json under target",
+			config: config.ProcessorConfig{Type: "json", Field: "payload", Target: "request"},
+			in:     types.LogFormat{Fields: map[string]interface{}{"payload": `{"id": "a1"}`}},
+			want: types.LogFormat{Fields: map[string]interface{}{
+				"payload": `{"id": "a1"}`,
+				"request": map[string]interface{}{"id": "a1"},
+			}},
+		},
+		{
+			name:   "json ignores plain text",
+			config: config.ProcessorConfig{Type: "json"},
+			in:     types.LogFormat{Message: "not json"},
+			want:   types.LogFormat{Message: "not json"},
+		},
+		{
+			name:    "json reports invalid objects",
+			config:  config.ProcessorConfig{Type: "json"},
+			in:      types.LogFormat{Message: `{"broken`},
+			want:    types.LogFormat{Message: `{"broken`},
+			wantErr: true,
+		},
+		{
+			name:   "rename field",
+			config: config.ProcessorConfig{Type: "rename", From: "msg", To: "message"},
+			in:     types.LogFormat{Fields: map[string]interface{}{"msg": "hello"}},
+			want:   types.LogFormat{Message: "hello", Fields: map[string]interface{}{}},
+		},
+		{
+			name:   "rename missing field",
+			config: config.ProcessorConfig{Type: "rename", From: "msg", To: "message"},
+			in:     types.LogFormat{Message: "kept"},
+			want:   types.LogFormat{Message: "kept"},
+		},
+		{
+			name:   "remove fields",
+			config: config.ProcessorConfig{Type: "remove", Field: "level", Fields: []string{"password", "missing"}},
+			in:     types.LogFormat{Level: "info", Fields: map[string]interface{}{"password": "x", "user": "ana"}},
+			want:   types.LogFormat{Fields: map[string]interface{}{"user": "ana"}},
+		},
+		{
+			name:   "set field and values",
+			config: config.ProcessorConfig{Type: "set", Field: "env", Value: "prod", Values: map[string]interface{}{"level": "info"}},
+			in:     types.LogFormat{Message: "up"},
+			want:   types.LogFormat{Level: "info", Message: "up", Fields: map[string]interface{}{"env": "prod"}},
+		},
+		{
+			name:   "level alias",
+			config: config.ProcessorConfig{Type: "level"},
+			in:     types.LogFormat{Level: " WARNING "},
+			want:   types.LogFormat{Level: "warn"},
+		},
+		{
+			name:   "level custom mapping from field",
+			config: config.ProcessorConfig{Type: "level", Field: "severity", Mapping: map[string]string{"SEV1": "critical"}},
+			in:     types.LogFormat{Fields: map[string]interface{}{"severity": "Sev1"}},
+			want:   types.LogFormat{Level: "critical", Fields: map[string]interface{}{"severity": "Sev1"}},
+		},
+		{
+			name:   "level unknown value is lower-cased",
+			config: config.ProcessorConfig{Type: "level"},
+			in:     types.LogFormat{Level: "VERBOSE"},
+			want:   types.LogFormat{Level: "verbose"},
+		},
+		{
+			name:    "drop",
+			config:  config.ProcessorConfig{Type: "drop"},
+			in:      types.LogFormat{Message: "gone"},
+			want:    types.LogFormat{Message: "gone"},
+			dropped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := (&Manager{}).newProcessor(tt.config)
+			if err != nil {
+				t.Fatalf("newProcessor: %v", err)
+			}
+			logs := tt.in
+			keep, err := p.Process(&logs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Process error = %v, want error %v", err, tt.wantErr)
+			}
+			if keep == tt.dropped {
+				t.Fatalf("Process keep = %v, want %v", keep, !tt.dropped)
+			}
+			if !reflect.DeepEqual(logs, tt.want) {
+				t.Errorf("Process = %+v, want %+v", logs, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessorConfigErrors(t *testing.T) {
+	tests := []config.ProcessorConfig{
+		{Type: "unknown"},
+		{Type: "grok"},
+		{Type: "grok", Pattern: "%{NOPE:x}"},
+		{Type: "rename", From: "a"},
+		{Type: "remove"},
+		{Type: "set"},
+		{Type: "route"},
+		{Type: "route", Routes: []config.RouteConfig{{Pipeline: "missing"}}},
+	}
+	for _, pc := range tests {
+		if _, err := (&Manager{}).newProcessor(pc); err == nil {
+			t.Errorf("newProcessor(%+v) succeeded, want error", pc)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+type route struct {
+	condition *condition
+	pipeline  *Pipeline
+}
+
+// routeProcessor hands the log to the first pipeline whose condition
+// matches. The rest of the current pipeline is skipped when a route is
+// taken.
+type routeProcessor struct {
+	routes []route
+}
+
+func newRouteProcessor(m *Manager, pc config.ProcessorConfig) (*routeProcessor, error) {
+	if len(pc.Routes) == 0 {
+		return nil, errors.New("route requires routes")
+	}
+
+	p := &routeProcessor{}
+	for _, rc := range pc.Routes {
+		target, ok := m.pipelines[rc.Pipeline]
+		if !ok {
+			return nil, fmt.Errorf("route to unknown pipeline %q", rc.Pipeline)
+		}
+		cond, err := newCondition(rc.If)
+		if err != nil {
+			return nil, err
+		}
+		p.routes = append(p.routes, route{condition: cond, pipeline: target})
+	}
+	return p, nil
+}
+
+func (p *routeProcessor) Process(logs *types.LogFormat) (bool, error) {
+	keep, _ := p.route(logs, 0)
+	return keep, nil
+}
+
+// route runs the first matching pipeline and reports whether one was taken.
+func (p *routeProcessor) route(logs *types.LogFormat, depth int) (keep bool, routed bool) {
+	if depth >= maxRouteDepth {
+		log.Printf("Not routing log, more than %d nested routes", maxRouteDepth)
+		return true, false
+	}
+	for _, r := range p.routes {
+		if r.condition.match(logs) {
+			return r.pipeline.run(logs, depth+1), true
+		}
+	}
+	return true, false
+}
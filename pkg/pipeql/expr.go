@@ -0,0 +1,231 @@
+package pipeql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Row is one log, or one stats group, as field name to value. Structured
+// fields use their plain names, without the "fields." prefix.
+type Row map[string]interface{}
+
+// Expr is a where condition.
+type Expr interface {
+	Match(row Row) bool
+}
+
+// And matches when both sides match.
+type And struct{ Left, Right Expr }
+
+// Or matches when either side matches.
+type Or struct{ Left, Right Expr }
+
+// Not inverts Expr.
+type Not struct{ Expr Expr }
+
+// Exists matches rows that have Field.
+type Exists struct{ Field string }
+
+// Compare compares Field with Value. Op is one of = != > >= < <= and ~,
+// which matches a regular expression.
+type Compare struct {
+	Field string
+	Op    string
+	Value interface{}
+
+	pattern *regexp.Regexp
+}
+
+func (e And) Match(row Row) bool { return e.Left.Match(row) && e.Right.Match(row) }
+func (e Or) Match(row Row) bool  { return e.Left.Match(row) || e.Right.Match(row) }
+func (e Not) Match(row Row) bool { return !e.Expr.Match(row) }
+
+func (e Exists) Match(row Row) bool {
+	_, ok := row[e.Field]
+	return ok
+}
+
+func (e Compare) Match(row Row) bool {
+	value, ok := row[e.Field]
+	if !ok {
+		return e.Op == "!="
+	}
+	if e.Op == "~" {
+		return e.pattern != nil && e.pattern.MatchString(toString(value))
+	}
+
+	c, comparable := compareValues(value, e.Value)
+	switch e.Op {
+	case "=":
+		return comparable && c == 0
+	case "!=":
+		return !comparable || c != 0
+	case ">":
+		return comparable && c > 0
+	case ">=":
+		return comparable && c >= 0
+	case "<":
+		return comparable && c < 0
+	default:
+		return comparable && c <= 0
+	}
+}
+
+// or parses: and ("or" and)*
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{left, right}
+	}
+	return left, nil
+}
+
+// and parses: not ("and" not)*
+func (p *parser) and() (Expr, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = And{left, right}
+	}
+	return left, nil
+}
+
+// not parses: "not" not | "(" or ")" | comparison
+func (p *parser) not() (Expr, error) {
+	if p.accept("not") {
+		expr, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return Not{expr}, nil
+	}
+	if p.accept("(") {
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.expect(")")
+	}
+	return p.comparison()
+}
+
+// comparison parses: field [op value]. A field alone tests that it exists.
+func (p *parser) comparison() (Expr, error) {
+	field, err := p.field()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	if op.kind != tokenSymbol || !strings.Contains(" = == != > >= < <= ~ ", " "+op.text+" ") {
+		return Exists{Field: field}, nil
+	}
+	p.next()
+
+	compare := Compare{Field: field, Op: op.text}
+	if compare.Op == "==" {
+		compare.Op = "="
+	}
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		compare.Value, _ = strconv.ParseFloat(t.text, 64)
+	case tokenString:
+		compare.Value = t.value
+	case tokenWord:
+		compare.Value = t.text
+	default:
+		return nil, fmt.Errorf("expected a value after %s, got %v", op.text, t)
+	}
+	if compare.Op == "~" {
+		compare.pattern, err = regexp.Compile(toString(compare.Value))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return compare, nil
+}
+
+// toNumber converts numbers and numeric strings.
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// compareValues orders a and b as numbers or timestamps when both convert,
+// and as strings otherwise. Comparable is false when a value is missing.
+func compareValues(a, b interface{}) (c int, comparable bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			return compareOrdered(x, y), true
+		}
+	}
+	if x, ok := toTime(a); ok {
+		if y, ok := toTime(b); ok {
+			return x.Compare(y), true
+		}
+	}
+	return strings.Compare(toString(a), toString(b)), true
+}
+
+func compareOrdered(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,132 @@
+package pipeql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string // unquoted text of a string
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of stage"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// splitStages splits a query on the pipes outside quotes.
+func splitStages(input string) ([]string, error) {
+	var stages []string
+	var quote rune
+	start := 0
+	escaped := false
+	for i, r := range input {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '|':
+			stages = append(stages, strings.TrimSpace(input[start:i]))
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated string")
+	}
+	return append(stages, strings.TrimSpace(input[start:])), nil
+}
+
+// symbols are matched longest first.
+var symbols = []string{"==", "!=", ">=", "<=", "=", ">", "<", "~", ",", "(", ")", "-", "+"}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.@*:/", r)
+}
+
+// lex splits a stage into tokens. Words may contain dots so structured
+// field names such as http.status stay one token.
+func lex(stage string) ([]token, error) {
+	var tokens []token
+	runes := []rune(stage)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			var value strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				value.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[i : j+1]), value: value.String()})
+			i = j + 1
+		case isWordRune(r) || r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && numberMayFollow(tokens):
+			// words may contain inner dashes, as in checkout-api or dates
+			j := i + 1
+			for j < len(runes) && (isWordRune(runes[j]) || runes[j] == '-' && j+1 < len(runes) && isWordRune(runes[j+1])) {
+				j++
+			}
+			text := string(runes[i:j])
+			kind := tokenWord
+			if _, err := strconv.ParseFloat(text, 64); err == nil {
+				kind = tokenNumber
+			}
+			tokens = append(tokens, token{kind: kind, text: text})
+			i = j
+		default:
+			matched := false
+			for _, symbol := range symbols {
+				if strings.HasPrefix(string(runes[i:]), symbol) {
+					tokens = append(tokens, token{kind: tokenSymbol, text: symbol})
+					i += len([]rune(symbol))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// numberMayFollow tells whether a '-' starts a negative number rather than
+// a descending sort key.
+func numberMayFollow(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind == tokenSymbol && last.text != "," && last.text != ")"
+}
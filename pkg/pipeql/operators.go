@@ -0,0 +1,284 @@
+package pipeql
+
+import (
+	"sort"
+	"strings"
+)
+
+// operator evaluates one stage. push returns false once the operator needs
+// no more rows; flush is called after the last row.
+type operator interface {
+	push(row Row) bool
+	flush()
+}
+
+func newOperator(stage Stage, next operator) operator {
+	switch s := stage.(type) {
+	case Where:
+		return &whereOperator{expr: s.Expr, next: next}
+	case Fields:
+		return &fieldsOperator{names: s.Names, next: next}
+	case Stats:
+		return &statsOperator{stats: s, groups: map[string]*statsGroup{}, next: next}
+	case Sort:
+		return &sortOperator{keys: s.Keys, next: next}
+	case Head:
+		return &headOperator{n: s.N, next: next}
+	default:
+		return &dedupOperator{fields: s.(Dedup).Fields, seen: map[string]bool{}, next: next}
+	}
+}
+
+type whereOperator struct {
+	expr Expr
+	next operator
+}
+
+func (o *whereOperator) push(row Row) bool {
+	if !o.expr.Match(row) {
+		return true
+	}
+	return o.next.push(row)
+}
+
+func (o *whereOperator) flush() { o.next.flush() }
+
+type fieldsOperator struct {
+	names []string
+	next  operator
+}
+
+func (o *fieldsOperator) push(row Row) bool {
+	kept := make(Row, len(o.names))
+	for _, name := range o.names {
+		if value, ok := row[name]; ok {
+			kept[name] = value
+		}
+	}
+	return o.next.push(kept)
+}
+
+func (o *fieldsOperator) flush() { o.next.flush() }
+
+type headOperator struct {
+	n, seen int
+	next    operator
+}
+
+func (o *headOperator) push(row Row) bool {
+	if o.seen >= o.n {
+		return false
+	}
+	o.seen++
+	return o.next.push(row) && o.seen < o.n
+}
+
+func (o *headOperator) flush() { o.next.flush() }
+
+type dedupOperator struct {
+	fields []string
+	seen   map[string]bool
+	next   operator
+}
+
+func (o *dedupOperator) push(row Row) bool {
+	key := groupKey(row, o.fields)
+	if o.seen[key] {
+		return true
+	}
+	o.seen[key] = true
+	return o.next.push(row)
+}
+
+func (o *dedupOperator) flush() { o.next.flush() }
+
+// groupKey joins the values of fields; missing fields differ from empty
+// strings.
+func groupKey(row Row, fields []string) string {
+	var key strings.Builder
+	for _, field := range fields {
+		if value, ok := row[field]; ok {
+			key.WriteString("=" + toString(value))
+		} else {
+			key.WriteString("!")
+		}
+		key.WriteByte(0)
+	}
+	return key.String()
+}
+
+type sortOperator struct {
+	keys []SortKey
+	rows []Row
+	next operator
+}
+
+func (o *sortOperator) push(row Row) bool {
+	o.rows = append(o.rows, row)
+	return true
+}
+
+// flush emits the rows in order. Rows missing a key sort last.
+func (o *sortOperator) flush() {
+	sort.SliceStable(o.rows, func(i, j int) bool {
+		for _, key := range o.keys {
+			a, aok := o.rows[i][key.Field]
+			b, bok := o.rows[j][key.Field]
+			if !aok || !bok {
+				if aok != bok {
+					return aok
+				}
+				continue
+			}
+			c, _ := compareValues(a, b)
+			if c == 0 {
+				continue
+			}
+			return c < 0 != key.Descending
+		}
+		return false
+	})
+	for _, row := range o.rows {
+		if !o.next.push(row) {
+			break
+		}
+	}
+	o.rows = nil
+	o.next.flush()
+}
+
+type statsOperator struct {
+	stats  Stats
+	groups map[string]*statsGroup
+	order  []*statsGroup
+	next   operator
+}
+
+type statsGroup struct {
+	by   Row
+	accs []*accumulator
+}
+
+func (o *statsOperator) push(row Row) bool {
+	key := groupKey(row, o.stats.By)
+	group, ok := o.groups[key]
+	if !ok {
+		group = &statsGroup{by: Row{}}
+		for _, field := range o.stats.By {
+			if value, ok := row[field]; ok {
+				group.by[field] = value
+			}
+		}
+		for _, agg := range o.stats.Aggregates {
+			group.accs = append(group.accs, &accumulator{agg: agg})
+		}
+		o.groups[key] = group
+		o.order = append(o.order, group)
+	}
+	for _, acc := range group.accs {
+		acc.add(row)
+	}
+	return true
+}
+
+// flush emits one row per group in the order the groups were first seen.
+func (o *statsOperator) flush() {
+	for _, group := range o.order {
+		row := Row{}
+		for field, value := range group.by {
+			row[field] = value
+		}
+		for _, acc := range group.accs {
+			if value := acc.result(); value != nil {
+				row[acc.agg.Name] = value
+			}
+		}
+		if !o.next.push(row) {
+			break
+		}
+	}
+	o.groups, o.order = nil, nil
+	o.next.flush()
+}
+
+type accumulator struct {
+	agg      Aggregate
+	count    int
+	sum      float64
+	numbers  int
+	extreme  interface{}
+	distinct map[string]bool
+}
+
+func (a *accumulator) add(row Row) {
+	if a.agg.Field == "" {
+		a.count++
+		return
+	}
+	value, ok := row[a.agg.Field]
+	if !ok || value == nil {
+		return
+	}
+	a.count++
+
+	switch a.agg.Func {
+	case "sum", "avg":
+		if n, ok := toNumber(value); ok {
+			a.sum += n
+			a.numbers++
+		}
+	case "min", "max":
+		if a.extreme == nil {
+			a.extreme = value
+			return
+		}
+		c, _ := compareValues(value, a.extreme)
+		if a.agg.Func == "min" && c < 0 || a.agg.Func == "max" && c > 0 {
+			a.extreme = value
+		}
+	case "dc":
+		if a.distinct == nil {
+			a.distinct = map[string]bool{}
+		}
+		a.distinct[toString(value)] = true
+	}
+}
+
+func (a *accumulator) result() interface{} {
+	switch a.agg.Func {
+	case "count":
+		return float64(a.count)
+	case "sum":
+		if a.numbers == 0 {
+			return nil
+		}
+		return a.sum
+	case "avg":
+		if a.numbers == 0 {
+			return nil
+		}
+		return a.sum / float64(a.numbers)
+	case "min", "max":
+		return a.extreme
+	default:
+		return float64(len(a.distinct))
+	}
+}
+
+// sink collects the result rows, at most max of them.
+type sink struct {
+	rows      []Row
+	max       int
+	truncated bool
+}
+
+func (s *sink) push(row Row) bool {
+	if len(s.rows) >= s.max {
+		s.truncated = true
+		return false
+	}
+	s.rows = append(s.rows, row)
+	return true
+}
+
+func (s *sink) flush() {}
@@ -0,0 +1,154 @@
+package pipeql
+
+import (
+	"reflect"
+	"testing"
+)
+
+// run pushes rows through query until it needs no more and returns the
+// result and how many rows were pushed.
+func run(t *testing.T, query string, maxRows int, rows []Row) ([]Row, bool, int) {
+	t.Helper()
+	plan, err := Compile(query)
+	if err != nil {
+		t.Fatalf("Compile(%q): %v", query, err)
+	}
+	execution := plan.Execute(maxRows)
+	pushed := 0
+	for _, row := range rows {
+		pushed++
+		if !execution.Push(row) {
+			break
+		}
+	}
+	result, truncated := execution.Finish()
+	return result, truncated, pushed
+}
+
+var testRows = []Row{
+	{"service": "api", "latency_ms": 120.0, "user": "ana"},
+	{"service": "web", "latency_ms": 40.0, "user": "bo"},
+	{"service": "api", "latency_ms": 300.0, "user": "ana"},
+	{"service": "api", "latency_ms": "90", "user": "cy"},
+	{"latency_ms": 10.0},
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		want   []Row
+		pushed int
+	}{
+		{
+			name:   "head stops early",
+			query:  "* | head 2",
+			want:   []Row{testRows[0], testRows[1]},
+			pushed: 2,
+		},
+		{
+			name:   "head after where stops early",
+			query:  "* | where service = api | head 2",
+			want:   []Row{testRows[0], testRows[2]},
+			pushed: 3,
+		},
+		{
+			name:   "head 0 takes nothing",
+			query:  "* | head 0",
+			want:   []Row{},
+			pushed: 1,
+		},
+		{
+			name:   "where compares numeric strings as numbers",
+			query:  "* | where latency_ms >= 90 and latency_ms < 300",
+			want:   []Row{testRows[0], testRows[3]},
+			pushed: 5,
+		},
+		{
+			name:   "missing fields differ",
+			query:  "* | where service != api",
+			want:   []Row{testRows[1], testRows[4]},
+			pushed: 5,
+		},
+		{
+			name:   "fields",
+			query:  "* | fields service, user | head 1",
+			want:   []Row{{"service": "api", "user": "ana"}},
+			pushed: 1,
+		},
+		{
+			name:  "stats by group in first seen order",
+			query: "* | stats count(), sum(latency_ms), avg(latency_ms) as avg, min(latency_ms), max(latency_ms), dc(user) by service",
+			want: []Row{
+				{"service": "api", "count": 3.0, "sum(latency_ms)": 510.0, "avg": 170.0, "min(latency_ms)": "90", "max(latency_ms)": 300.0, "dc(user)": 2.0},
+				{"service": "web", "count": 1.0, "sum(latency_ms)": 40.0, "avg": 40.0, "min(latency_ms)": 40.0, "max(latency_ms)": 40.0, "dc(user)": 1.0},
+				{"count": 1.0, "sum(latency_ms)": 10.0, "avg": 10.0, "min(latency_ms)": 10.0, "max(latency_ms)": 10.0, "dc(user)": 0.0},
+			},
+			pushed: 5,
+		},
+		{
+			name:   "stats without rows has no groups",
+			query:  "* | where service = db | stats count(user), sum(latency_ms)",
+			want:   []Row{},
+			pushed: 5,
+		},
+		{
+			name:  "sort descending with missing keys last",
+			query: "* | sort -service, latency_ms",
+			want: []Row{
+				testRows[1],
+				testRows[3], testRows[0], testRows[2],
+				testRows[4],
+			},
+			pushed: 5,
+		},
+		{
+			name:   "sort then head reads every row",
+			query:  "* | sort -latency_ms | head 2",
+			want:   []Row{testRows[2], testRows[0]},
+			pushed: 5,
+		},
+		{
+			name:  "stats then sort",
+			query: "* | stats count() by service | sort -count, service",
+			want: []Row{
+				{"service": "api", "count": 3.0},
+				{"service": "web", "count": 1.0},
+				{"count": 1.0},
+			},
+			pushed: 5,
+		},
+		{
+			name:   "dedup keeps the first row",
+			query:  "* | dedup service, user",
+			want:   []Row{testRows[0], testRows[1], testRows[3], testRows[4]},
+			pushed: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated, pushed := run(t, tt.query, 100, testRows)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %v, want %v", got, tt.want)
+			}
+			if truncated {
+				t.Errorf("truncated, want all rows")
+			}
+			if pushed != tt.pushed {
+				t.Errorf("pushed %d rows, want %d", pushed, tt.pushed)
+			}
+		})
+	}
+}
+
+func TestExecuteTruncates(t *testing.T) {
+	got, truncated, pushed := run(t, "*", 2, testRows)
+	if len(got) != 2 || !truncated || pushed != 3 {
+		t.Errorf("got %d rows, truncated %v, pushed %d, want 2, true, 3", len(got), truncated, pushed)
+	}
+
+	got, truncated, _ = run(t, "* | sort latency_ms", 2, testRows)
+	if !reflect.DeepEqual(got, []Row{testRows[4], testRows[1]}) || !truncated {
+		t.Errorf("sorted rows = %v, truncated %v, want the 2 fastest, true", got, truncated)
+	}
+}
@@ -0,0 +1,310 @@
+package pipeql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query is a parsed pipe query: a search followed by stages evaluated over
+// its matches, e.g.
+//
+//	level:error | where latency_ms > 500 | stats count() by service | sort -count | head 10
+type Query struct {
+	// Search is the first stage, a bleve query string. Empty or "*"
+	// matches every log.
+	Search string
+	Stages []Stage
+}
+
+// Stage is one command after a pipe: Where, Fields, Stats, Sort, Head or
+// Dedup.
+type Stage interface {
+	stage()
+}
+
+// Where keeps the rows matching Expr.
+type Where struct {
+	Expr Expr
+}
+
+// Fields keeps only the named fields of each row.
+type Fields struct {
+	Names []string
+}
+
+// Stats groups rows by the By fields and computes Aggregates per group.
+type Stats struct {
+	Aggregates []Aggregate
+	By         []string
+}
+
+// Aggregate is a stats function such as count() or avg(latency_ms), stored
+// in the output row as Name.
+type Aggregate struct {
+	Func  string
+	Field string
+	Name  string
+}
+
+// Sort orders rows by Keys.
+type Sort struct {
+	Keys []SortKey
+}
+
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// Head keeps the first N rows.
+type Head struct {
+	N int
+}
+
+// Dedup keeps the first row of every combination of Fields.
+type Dedup struct {
+	Fields []string
+}
+
+func (Where) stage()  {}
+func (Fields) stage() {}
+func (Stats) stage()  {}
+func (Sort) stage()   {}
+func (Head) stage()   {}
+func (Dedup) stage()  {}
+
+// aggregateFuncs are the stats functions. Only count works without a field.
+var aggregateFuncs = map[string]bool{
+	"count": true,
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+	"dc":    true,
+}
+
+const defaultHead = 10
+
+// Parse parses a pipe query.
+func Parse(input string) (*Query, error) {
+	stages, err := splitStages(input)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{Search: stages[0]}
+	for i, text := range stages[1:] {
+		stage, err := parseStage(text)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d (%s): %w", i+2, text, err)
+		}
+		q.Stages = append(q.Stages, stage)
+	}
+	return q, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		return token{kind: tokenEOF}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the symbol or keyword text.
+func (p *parser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == tokenSymbol || t.kind == tokenWord) && strings.EqualFold(t.text, text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("expected %q, got %v", text, p.peek())
+	}
+	return nil
+}
+
+// field parses a field name, which may be quoted, as in "avg(latency_ms)".
+func (p *parser) field() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenWord:
+		return strings.TrimPrefix(t.text, "fields."), nil
+	case tokenString:
+		return t.value, nil
+	default:
+		return "", fmt.Errorf("expected a field name, got %v", t)
+	}
+}
+
+// fieldList parses "a, b, c".
+func (p *parser) fieldList() ([]string, error) {
+	var fields []string
+	for {
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		if !p.accept(",") {
+			return fields, nil
+		}
+	}
+}
+
+func (p *parser) end() error {
+	if t := p.peek(); t.kind != tokenEOF {
+		return fmt.Errorf("unexpected %v", t)
+	}
+	return nil
+}
+
+func parseStage(text string) (Stage, error) {
+	tokens, err := lex(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	command := p.next()
+	if command.kind != tokenWord {
+		return nil, fmt.Errorf("expected a command, got %v", command)
+	}
+
+	var stage Stage
+	switch strings.ToLower(command.text) {
+	case "where":
+		var expr Expr
+		expr, err = p.or()
+		stage = Where{Expr: expr}
+	case "fields":
+		var names []string
+		names, err = p.fieldList()
+		stage = Fields{Names: names}
+	case "stats":
+		stage, err = p.stats()
+	case "sort":
+		stage, err = p.sort()
+	case "head":
+		head := Head{N: defaultHead}
+		if t := p.peek(); t.kind == tokenNumber {
+			p.next()
+			head.N, err = strconv.Atoi(t.text)
+			if err != nil || head.N < 0 {
+				err = fmt.Errorf("invalid count %v", t)
+			}
+		}
+		stage = head
+	case "dedup":
+		var fields []string
+		fields, err = p.fieldList()
+		stage = Dedup{Fields: fields}
+	default:
+		return nil, fmt.Errorf("unknown command %q", command.text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stage, p.end()
+}
+
+func (p *parser) stats() (Stage, error) {
+	var stats Stats
+	names := map[string]bool{}
+	for {
+		agg, err := p.aggregate()
+		if err != nil {
+			return nil, err
+		}
+		if names[agg.Name] {
+			return nil, fmt.Errorf("duplicate aggregate %q", agg.Name)
+		}
+		names[agg.Name] = true
+		stats.Aggregates = append(stats.Aggregates, agg)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if p.accept("by") {
+		by, err := p.fieldList()
+		if err != nil {
+			return nil, err
+		}
+		stats.By = by
+	}
+	return stats, nil
+}
+
+func (p *parser) aggregate() (Aggregate, error) {
+	var agg Aggregate
+	t := p.next()
+	agg.Func = strings.ToLower(t.text)
+	if t.kind != tokenWord || !aggregateFuncs[agg.Func] {
+		return agg, fmt.Errorf("expected count, sum, avg, min, max or dc, got %v", t)
+	}
+	if err := p.expect("("); err != nil {
+		return agg, err
+	}
+	if !p.accept(")") {
+		field, err := p.field()
+		if err != nil {
+			return agg, err
+		}
+		if field != "*" {
+			agg.Field = field
+		}
+		if err := p.expect(")"); err != nil {
+			return agg, err
+		}
+	}
+	if agg.Field == "" && agg.Func != "count" {
+		return agg, fmt.Errorf("%s needs a field", agg.Func)
+	}
+
+	agg.Name = agg.Func
+	if agg.Field != "" {
+		agg.Name = agg.Func + "(" + agg.Field + ")"
+	}
+	if p.accept("as") {
+		name, err := p.field()
+		if err != nil {
+			return agg, err
+		}
+		agg.Name = name
+	}
+	return agg, nil
+}
+
+func (p *parser) sort() (Stage, error) {
+	var sort Sort
+	for {
+		descending := p.accept("-")
+		if !descending {
+			p.accept("+")
+		}
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		sort.Keys = append(sort.Keys, SortKey{Field: field, Descending: descending})
+		if !p.accept(",") {
+			return sort, nil
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package pipeql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  *Query
+	}{
+		{
+			name:  "search only",
+			input: "level:error",
+			want:  &Query{Search: "level:error"},
+		},
+		{
+			name:  "and binds tighter than or",
+			input: "* | where a = 1 or b = 2 and not c",
+			want: &Query{Search: "*", Stages: []Stage{Where{Expr: Or{
+				Compare{Field: "a", Op: "=", Value: 1.0},
+				And{Compare{Field: "b", Op: "=", Value: 2.0}, Not{Exists{Field: "c"}}},
+			}}}},
+		},
+		{
+			name:  "parentheses group",
+			input: "* | where (a == 1 or b != x) and c",
+			want: &Query{Search: "*", Stages: []Stage{Where{Expr: And{
+				Or{Compare{Field: "a", Op: "=", Value: 1.0}, Compare{Field: "b", Op: "!=", Value: "x"}},
+				Exists{Field: "c"},
+			}}}},
+		},
+		{
+			name:  "pipes inside quotes",
+			input: `message:"a|b" | where path = "/x|y" and note = 'it\'s | fine'`,
+			want: &Query{Search: `message:"a|b"`, Stages: []Stage{Where{Expr: And{
+				Compare{Field: "path", Op: "=", Value: "/x|y"},
+				Compare{Field: "note", Op: "=", Value: "it's | fine"},
+			}}}},
+		},
+		{
+			name:  "negative numbers and dashed words",
+			input: "* | where offset > -5 and service = checkout-api",
+			want: &Query{Search: "*", Stages: []Stage{Where{Expr: And{
+				Compare{Field: "offset", Op: ">", Value: -5.0},
+				Compare{Field: "service", Op: "=", Value: "checkout-api"},
+			}}}},
+		},
+		{
+			name:  "stats with names and groups",
+			input: "* | stats count(), avg(latency_ms) as lat, dc(fields.user) by service, http.host",
+			want: &Query{Search: "*", Stages: []Stage{Stats{
+				Aggregates: []Aggregate{
+					{Func: "count", Name: "count"},
+					{Func: "avg", Field: "latency_ms", Name: "lat"},
+					{Func: "dc", Field: "user", Name: "dc(user)"},
+				},
+				By: []string{"service", "http.host"},
+			}}},
+		},
+		{
+			name:  "count star",
+			input: "* | stats COUNT(*)",
+			want:  &Query{Search: "*", Stages: []Stage{Stats{Aggregates: []Aggregate{{Func: "count", Name: "count"}}}}},
+		},
+		{
+			name:  "sort, head, fields and dedup",
+			input: `* | sort -count, +service, "avg(latency_ms)" | head | head 3 | fields service, count | dedup service`,
+			want: &Query{Search: "*", Stages: []Stage{
+				Sort{Keys: []SortKey{
+					{Field: "count", Descending: true},
+					{Field: "service"},
+					{Field: "avg(latency_ms)"},
+				}},
+				Head{N: defaultHead},
+				Head{N: 3},
+				Fields{Names: []string{"service", "count"}},
+				Dedup{Fields: []string{"service"}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRegexp(t *testing.T) {
+	q, err := Parse(`* | where message ~ "^time(out|d out)"`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	where := q.Stages[0].(Where)
+	if !where.Expr.Match(Row{"message": "timed out"}) || where.Expr.Match(Row{"message": "it timed out"}) {
+		t.Errorf("regexp condition matched the wrong rows")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		`message:"open`,
+		"* | where a = 'open",
+		"* | explode",
+		"* | where a =",
+		"* | where (a = 1",
+		"* | where a ~ '('",
+		"* | stats",
+		"* | stats sum()",
+		"* | stats median(a)",
+		"* | stats count(), count()",
+		"* | stats count() by",
+		"* | head -1",
+		"* | head 3 4",
+		"* | sort",
+		"* | dedup",
+		"* |",
+	} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestCompileLimit(t *testing.T) {
+	tests := []struct {
+		input  string
+		search string
+		limit  int
+	}{
+		{input: "*", search: "", limit: 0},
+		{input: "level:error | head 5", search: "level:error", limit: 5},
+		{input: "* | fields message | head 5 | head 2", search: "", limit: 5},
+		{input: "* | where a = 1 | head 5", search: "", limit: 0},
+		{input: "* | sort -timestamp | head 5", search: "", limit: 0},
+	}
+	for _, tt := range tests {
+		plan, err := Compile(tt.input)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tt.input, err)
+		}
+		if plan.Search != tt.search || plan.Limit != tt.limit {
+			t.Errorf("Compile(%q) = search %q, limit %d, want %q, %d", tt.input, plan.Search, plan.Limit, tt.search, tt.limit)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package pipeql
+
+// Plan is a compiled query. Its search is pushed down to the index and its
+// stages run over the matches, which are pushed in timestamp order.
+type Plan struct {
+	// Search is the bleve query string selecting the logs; empty matches
+	// every log.
+	Search string
+	// Limit is the most matches the stages can use, when that is known up
+	// front (a head behind nothing but fields); 0 means no limit.
+	Limit int
+
+	stages []Stage
+}
+
+// Compile parses a query and plans its execution.
+func Compile(input string) (*Plan, error) {
+	q, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Search: q.Search, stages: q.Stages}
+	if plan.Search == "*" {
+		plan.Search = ""
+	}
+	for _, stage := range q.Stages {
+		if head, ok := stage.(Head); ok {
+			plan.Limit = head.N
+			break
+		}
+		if _, ok := stage.(Fields); !ok {
+			break
+		}
+	}
+	return plan, nil
+}
+
+// Execution is one run of a plan.
+type Execution struct {
+	first operator
+	sink  *sink
+	done  bool
+}
+
+// Execute starts a run that returns at most maxRows rows.
+func (p *Plan) Execute(maxRows int) *Execution {
+	s := &sink{max: maxRows}
+	var first operator = s
+	for i := len(p.stages) - 1; i >= 0; i-- {
+		first = newOperator(p.stages[i], first)
+	}
+	return &Execution{first: first, sink: s}
+}
+
+// Push feeds the next match. It returns false once no more are needed.
+func (e *Execution) Push(row Row) bool {
+	if e.done {
+		return false
+	}
+	if !e.first.push(row) {
+		e.done = true
+	}
+	return !e.done
+}
+
+// Finish runs the blocking stages and returns the result. Truncated is true
+// when it was cut at maxRows.
+func (e *Execution) Finish() (rows []Row, truncated bool) {
+	e.first.flush()
+	if e.sink.rows == nil {
+		e.sink.rows = []Row{}
+	}
+	return e.sink.rows, e.sink.truncated
+}
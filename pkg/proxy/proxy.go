@@ -1,13 +1,18 @@
 package proxy
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 
+	"github.com/adiyakaihsan/go-logger/pkg/auth"
+	"github.com/adiyakaihsan/go-logger/pkg/tlsconfig"
 	"github.com/adiyakaihsan/go-logger/pkg/types"
 	"github.com/julienschmidt/httprouter"
 	"github.com/serialx/hashring"
@@ -16,6 +21,65 @@ import (
 type Proxy struct {
 	backends []string
 	ring *hashring.HashRing
+	auth     *auth.Store
+	client   *http.Client
+}
+
+type Config struct {
+	Port         string
+	Backends     []string
+	AuthKeysFile string
+	// TLS serves the proxy over HTTPS, BackendTLS configures the connection
+	// to the backends.
+	TLS        tlsconfig.ServerOptions
+	BackendTLS tlsconfig.ClientOptions
+	// RequireClientCert restricts ingest to clients presenting a certificate
+	// signed by TLS.ClientCAFile.
+	RequireClientCert bool
+}
+
+// forwardIdentity passes the authenticated caller on to the backend. The
+// original API key header is forwarded too, so backends sharing the key file
+// re-check it. Only admin keys may choose the tenant themselves.
+func forwardIdentity(r *http.Request, proxyReq *http.Request) {
+	id, ok := auth.FromContext(r.Context())
+	if !ok {
+		return
+	}
+	proxyReq.Header.Set("X-Forwarded-Identity", id.Name)
+	switch {
+	case id.Tenant != "":
+		proxyReq.Header.Set("X-Tenant-ID", id.Tenant)
+	case id.Role != auth.RoleAdmin:
+		proxyReq.Header.Del("X-Tenant-ID")
+	}
+}
+
+// ingest protects an ingest route with the ingest role and, optionally, a
+// verified client certificate.
+func (p *Proxy) ingest(requireClientCert bool, handle httprouter.Handle) httprouter.Handle {
+	if requireClientCert {
+		handle = tlsconfig.RequireClientCert(handle)
+	}
+	return p.require(auth.RoleIngest, handle)
+}
+
+// require protects a route with role when API keys are configured.
+func (p *Proxy) require(role auth.Role, handle httprouter.Handle) httprouter.Handle {
+	if p.auth == nil {
+		return handle
+	}
+	return p.auth.Require(role, handle)
+}
+
+// requestBody returns the request body, decompressed when it is sent with
+// Content-Encoding: gzip. Bodies are forwarded decompressed because they are
+// split and rehashed.
+func requestBody(r *http.Request) (io.Reader, error) {
+	if !strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		return r.Body, nil
+	}
+	return gzip.NewReader(r.Body)
 }
 
 func (p *Proxy) proxySearch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -51,9 +115,9 @@ func (p *Proxy) proxySearch(w http.ResponseWriter, r *http.Request, _ httprouter
 		// Add X-Forwarded headers
 		proxyReq.Header.Set("X-Forwarded-Host", r.Host)
 		proxyReq.Header.Set("X-Forwarded-Proto", r.URL.Scheme)
+		forwardIdentity(r, proxyReq)
 
-		client := &http.Client{}
-		resp, err := client.Do(proxyReq)
+		resp, err := p.client.Do(proxyReq)
 		if err != nil {
 			http.Error(w, "Error sending proxy request", http.StatusBadGateway)
 			log.Printf("Error1: %v", err)
@@ -77,8 +141,13 @@ func (p *Proxy) proxyIngest(w http.ResponseWriter, r *http.Request, _ httprouter
 	var logs types.LogFormat
 	var buf bytes.Buffer
 
-	tee := io.TeeReader(r.Body, &buf)
-	
+	body, err := requestBody(r)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+	tee := io.TeeReader(body, &buf)
+
 	if err := json.NewDecoder(tee).Decode(&logs); err != nil {
 		log.Printf("Cannot decode log. Error: %v", err)
 		return
@@ -98,13 +167,14 @@ func (p *Proxy) proxyIngest(w http.ResponseWriter, r *http.Request, _ httprouter
 			proxyReq.Header.Add(header, value)
 		}
 	}
+	proxyReq.Header.Del("Content-Encoding")
 
 	// Add X-Forwarded headers
 	proxyReq.Header.Set("X-Forwarded-Host", r.Host)
 	proxyReq.Header.Set("X-Forwarded-Proto", r.URL.Scheme)
+	forwardIdentity(r, proxyReq)
 
-	client := &http.Client{}
-	resp, err := client.Do(proxyReq)
+	resp, err := p.client.Do(proxyReq)
 	if err != nil {
 		http.Error(w, "Error sending proxy request", http.StatusBadGateway)
 		log.Printf("Error1: %v", err)
@@ -119,24 +189,133 @@ func (p *Proxy) proxyIngest(w http.ResponseWriter, r *http.Request, _ httprouter
 
 }
 
-func Run() {	
+// proxyBulk splits a NDJSON body by the hash ring and forwards each part to
+// the bulk endpoint of its backend.
+func (p *Proxy) proxyBulk(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	defer r.Body.Close()
+
+	body, err := requestBody(r)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	batches := map[string]*bytes.Buffer{}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		server, _ := p.ring.GetNode(string(line))
+		if _, ok := batches[server]; !ok {
+			batches[server] = &bytes.Buffer{}
+		}
+		batches[server].Write(line)
+		batches[server].WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var total types.BulkResult
+	for server, body := range batches {
+		targetUrl := fmt.Sprintf("%s%s", server, r.URL)
+		proxyReq, err := http.NewRequest(r.Method, targetUrl, body)
+		if err != nil {
+			log.Printf("Cannot proxy request. Error: %v", err)
+			continue
+		}
+		for header, values := range r.Header {
+			for _, value := range values {
+				proxyReq.Header.Add(header, value)
+			}
+		}
+		proxyReq.Header.Del("Content-Length")
+		proxyReq.Header.Del("Content-Encoding")
+
+		// Add X-Forwarded headers
+		proxyReq.Header.Set("X-Forwarded-Host", r.Host)
+		proxyReq.Header.Set("X-Forwarded-Proto", r.URL.Scheme)
+		forwardIdentity(r, proxyReq)
+
+		resp, err := p.client.Do(proxyReq)
+		if err != nil {
+			http.Error(w, "Error sending proxy request", http.StatusBadGateway)
+			log.Printf("Error1: %v", err)
+			return
+		}
+		var result types.BulkResult
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			log.Printf("Cannot decode bulk response from %v. Error: %v", server, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			http.Error(w, fmt.Sprintf("Backend %s returned %s", server, resp.Status), http.StatusBadGateway)
+			return
+		}
+		total.Accepted += result.Accepted
+		total.Failed += result.Failed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(total)
+}
+
+func Run(cfg Config) {
 	router := httprouter.New()
-	backends := []string{"http://localhost:8083", "http://localhost:8082"}
-	ring := hashring.New(backends)
+	ring := hashring.New(cfg.Backends)
 	// register backend
+	backendTLS, err := tlsconfig.Client(cfg.BackendTLS)
+	if err != nil {
+		log.Fatalf("Cannot configure backend TLS. Error: %v", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = backendTLS
+
 	p := &Proxy{
-		backends: backends,
+		backends: cfg.Backends,
 		ring: ring,
+		client:   &http.Client{Transport: transport},
+	}
+	if cfg.AuthKeysFile != "" {
+		keys, err := auth.LoadStore(cfg.AuthKeysFile)
+		if err != nil {
+			log.Fatalf("Cannot load API keys. Error: %v", err)
+		}
+		p.auth = keys
 	}
 
-	router.POST("/api/v1/log/search", p.proxySearch)
-	router.POST("/api/v1/log/ingest", p.proxyIngest)
+	router.POST("/api/v1/log/search", p.require(auth.RoleRead, p.proxySearch))
+	router.POST("/api/v1/log/ingest", p.ingest(cfg.RequireClientCert, p.proxyIngest))
+	router.POST("/api/v1/log/bulk", p.ingest(cfg.RequireClientCert, p.proxyBulk))
 
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%s", "8256"),
+		Addr:    fmt.Sprintf(":%s", cfg.Port),
 		Handler: router,
 	}
-	log.Printf("Starting server on port 8256")
+	if cfg.RequireClientCert && cfg.TLS.ClientCAFile == "" {
+		log.Fatal("Requiring client certificates needs a client CA file")
+	}
+	if err := cfg.TLS.Validate(); err != nil {
+		log.Fatalf("Cannot configure TLS. Error: %v", err)
+	}
+	if cfg.TLS.Enabled() {
+		tlsConfig, err := tlsconfig.Server(cfg.TLS)
+		if err != nil {
+			log.Fatalf("Cannot configure TLS. Error: %v", err)
+		}
+		server.TLSConfig = tlsConfig
+
+		log.Printf("Starting TLS server on port %s", cfg.Port)
+		err = server.ListenAndServeTLS("", "")
+		log.Printf("HTTP server error: %v", err)
+		return
+	}
+
+	log.Printf("Starting server on port %s", cfg.Port)
 	if err := server.ListenAndServe(); err != nil {
 		log.Printf("HTTP server error: %v", err)
 	}
@@ -0,0 +1,282 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+)
+
+const saveInterval = 10 * time.Second
+
+// Usage is the ingest volume of one key on one UTC day.
+type Usage struct {
+	Day     string `json:"day"`
+	Records int64  `json:"records"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Decision is the outcome of Allow, with the values reported in response
+// headers. TooLarge marks a request that could never be allowed because it
+// holds more records than the burst.
+type Decision struct {
+	Allowed          bool
+	TooLarge         bool
+	Reason           string
+	Limit            config.Limit
+	Remaining        int64
+	RecordsRemaining int64
+	BytesRemaining   int64
+	RetryAfter       time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter enforces token bucket rate limits and daily quotas per key. Daily
+// usage is persisted to a file so quotas survive restarts.
+type Limiter struct {
+	cfg config.RateLimitConfig
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	usage   map[string]*Usage
+	// changes counts usage updates, saved is changes as of the last
+	// successful save.
+	changes uint64
+	saved   uint64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New returns nil when neither a default nor an override limit is set.
+func New(cfg config.RateLimitConfig) (*Limiter, error) {
+	if cfg.Default == (config.Limit{}) && len(cfg.Overrides) == 0 {
+		return nil, nil
+	}
+	switch cfg.By {
+	case "":
+		cfg.By = "tenant"
+	case "api_key", "tenant", "source":
+	default:
+		return nil, fmt.Errorf("rate limits by unknown key %q", cfg.By)
+	}
+
+	l := &Limiter{
+		cfg:     cfg,
+		buckets: map[string]*bucket{},
+		usage:   map[string]*Usage{},
+		done:    make(chan struct{}),
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// By names what keys identify: api_key, tenant or source.
+func (l *Limiter) By() string {
+	return l.cfg.By
+}
+
+func (l *Limiter) limit(key string) config.Limit {
+	if limit, ok := l.cfg.Overrides[key]; ok {
+		return limit
+	}
+	return l.cfg.Default
+}
+
+func today(now time.Time) string {
+	return now.UTC().Format("2006-01-02")
+}
+
+func untilMidnight(now time.Time) time.Duration {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(now)
+}
+
+// Allow consumes records tokens and adds records and bytes to the daily
+// usage of key, unless a limit would be exceeded.
+func (l *Limiter) Allow(key string, records, bytes int64) Decision {
+	now := time.Now()
+	limit := l.limit(key)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d := Decision{Allowed: true, Limit: limit, Remaining: -1, RecordsRemaining: -1, BytesRemaining: -1}
+
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = math.Max(limit.RecordsPerSecond, 1)
+	}
+	if limit.RecordsPerSecond > 0 && float64(records) > burst {
+		d.Allowed, d.TooLarge = false, true
+		d.Reason = fmt.Sprintf("%d records exceed the burst of %v, split the request", records, burst)
+		return d
+	}
+
+	usage, ok := l.usage[key]
+	if !ok || usage.Day != today(now) {
+		usage = &Usage{Day: today(now)}
+		l.usage[key] = usage
+	}
+	if limit.DailyRecords > 0 {
+		d.RecordsRemaining = max(limit.DailyRecords-usage.Records, 0)
+		if usage.Records+records > limit.DailyRecords {
+			d.Allowed, d.Reason = false, "daily record quota exceeded"
+		}
+	}
+	if limit.DailyBytes > 0 {
+		d.BytesRemaining = max(limit.DailyBytes-usage.Bytes, 0)
+		if usage.Bytes+bytes > limit.DailyBytes {
+			d.Allowed, d.Reason = false, "daily byte quota exceeded"
+		}
+	}
+	if !d.Allowed {
+		d.RetryAfter = untilMidnight(now)
+		return d
+	}
+
+	if limit.RecordsPerSecond > 0 {
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{tokens: burst, last: now}
+			l.buckets[key] = b
+		}
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.RecordsPerSecond)
+		b.last = now
+
+		cost := float64(records)
+		if b.tokens < cost {
+			d.Allowed, d.Reason = false, "rate limit exceeded"
+			d.Remaining = int64(b.tokens)
+			d.RetryAfter = time.Duration((cost - b.tokens) / limit.RecordsPerSecond * float64(time.Second))
+			return d
+		}
+		b.tokens -= cost
+		d.Remaining = int64(b.tokens)
+	}
+
+	usage.Records += records
+	usage.Bytes += bytes
+	l.changes++
+	if d.RecordsRemaining >= 0 {
+		d.RecordsRemaining -= records
+	}
+	if d.BytesRemaining >= 0 {
+		d.BytesRemaining -= bytes
+	}
+	return d
+}
+
+// Usage returns today's usage of every key.
+func (l *Limiter) Usage() map[string]Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	day := today(time.Now())
+	usage := map[string]Usage{}
+	for key, u := range l.usage {
+		if u.Day == day {
+			usage[key] = *u
+		}
+	}
+	return usage
+}
+
+func (l *Limiter) load() error {
+	if l.cfg.UsageFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(l.cfg.UsageFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &l.usage); err != nil {
+		return fmt.Errorf("cannot parse usage file %v: %w", l.cfg.UsageFile, err)
+	}
+	return nil
+}
+
+// Save writes today's usage to the usage file if it changed.
+func (l *Limiter) Save() error {
+	if l.cfg.UsageFile == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.changes == l.saved {
+		l.mu.Unlock()
+		return nil
+	}
+	changes := l.changes
+	day := today(time.Now())
+	for key, u := range l.usage {
+		if u.Day != day {
+			delete(l.usage, key)
+		}
+	}
+	data, err := json.MarshalIndent(l.usage, "", "  ")
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.cfg.UsageFile), 0o755); err != nil {
+		return err
+	}
+	tmp := l.cfg.UsageFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.cfg.UsageFile); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.saved = changes
+	l.mu.Unlock()
+	return nil
+}
+
+// Start saves usage periodically until Close.
+func (l *Limiter) Start() {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+
+		ticker := time.NewTicker(saveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+				if err := l.Save(); err != nil {
+					log.Printf("Cannot save ingest usage. Error: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (l *Limiter) Close() error {
+	close(l.done)
+	l.wg.Wait()
+	return l.Save()
+}
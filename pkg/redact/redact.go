@@ -0,0 +1,323 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+var builtinPatterns = map[string]string{
+	"email":       `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	"credit_card": `\b\d(?:[ -]?\d){12,}\b`,
+	"ip":          `\b(?:\d{1,3}\.){3}\d{1,3}\b|\b(?:[0-9A-Fa-f]{1,4}:){2,7}[0-9A-Fa-f]{1,4}\b|\b(?:[0-9A-Fa-f]{1,4}:)+:(?:[0-9A-Fa-f]{1,4}:?)*\b`,
+	"jwt":         `\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`,
+}
+
+var builtinValidators = map[string]func(string) bool{
+	"ip": func(s string) bool {
+		return net.ParseIP(s) != nil
+	},
+}
+
+// builtinFinders replace matching and validating for types whose matches
+// are narrowed down further.
+var builtinFinders = map[string]func(re *regexp.Regexp, s string) [][]int{
+	"credit_card": cardSpans,
+}
+
+// cardLayouts are the digit groups card numbers are written in, besides a
+// single group of 13 to 19 digits.
+var cardLayouts = [][]int{
+	{4, 4, 4, 4},
+	{4, 4, 4, 4, 3},
+	{4, 6, 5},
+	{4, 6, 4},
+}
+
+type rule struct {
+	name     string
+	re       *regexp.Regexp
+	validate func(string) bool
+	find     func(re *regexp.Regexp, s string) [][]int
+	hash     bool
+	token    string
+	count    atomic.Int64
+}
+
+// Redactor masks sensitive values in logs and counts redactions per rule.
+type Redactor struct {
+	rules  []*rule
+	fields map[string]bool
+	salt   []byte
+}
+
+// New builds a Redactor. It returns nil when no rules are configured.
+func New(cfg config.RedactionConfig) (*Redactor, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, nil
+	}
+
+	r := &Redactor{salt: []byte(cfg.HashSalt)}
+	if len(cfg.Fields) > 0 {
+		r.fields = map[string]bool{}
+		for _, field := range cfg.Fields {
+			r.fields[field] = true
+		}
+	}
+
+	for _, rc := range cfg.Rules {
+		pattern := rc.Pattern
+		if rc.Type != "regex" {
+			builtin, ok := builtinPatterns[rc.Type]
+			if !ok {
+				return nil, fmt.Errorf("unknown redaction type %q", rc.Type)
+			}
+			pattern = builtin
+		}
+		if pattern == "" {
+			return nil, fmt.Errorf("redaction rule %q requires a pattern", rc.Name)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %q: %w", rc.Name, err)
+		}
+
+		name := rc.Name
+		if name == "" {
+			name = rc.Type
+		}
+		token := rc.Token
+		if token == "" {
+			token = fmt.Sprintf("[%s]", strings.ToUpper(name))
+		}
+		switch rc.Replace {
+		case "", "token", "hash":
+		default:
+			return nil, fmt.Errorf("redaction rule %q: unknown replace %q", name, rc.Replace)
+		}
+
+		r.rules = append(r.rules, &rule{
+			name:     name,
+			re:       re,
+			validate: builtinValidators[rc.Type],
+			find:     builtinFinders[rc.Type],
+			hash:     rc.Replace == "hash",
+			token:    token,
+		})
+	}
+	return r, nil
+}
+
+// Redact masks the message and structured fields of logs in place.
+func (r *Redactor) Redact(logs *types.LogFormat) {
+	if r.applies("message") {
+		logs.Message = r.redactString(logs.Message)
+	}
+	for key, value := range logs.Fields {
+		if r.applies(key) {
+			logs.Fields[key] = r.redactValue(value)
+		}
+	}
+}
+
+// Counts returns the number of redactions made by each rule so far.
+func (r *Redactor) Counts() map[string]int64 {
+	counts := map[string]int64{}
+	for _, rl := range r.rules {
+		counts[rl.name] = rl.count.Load()
+	}
+	return counts
+}
+
+func (r *Redactor) applies(field string) bool {
+	return r.fields == nil || r.fields[field]
+}
+
+func (r *Redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.redactString(v)
+	case map[string]interface{}:
+		for key, inner := range v {
+			v[key] = r.redactValue(inner)
+		}
+		return v
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = r.redactValue(inner)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func (r *Redactor) redactString(s string) string {
+	for _, rl := range r.rules {
+		spans := rl.spans(s)
+		if len(spans) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, span := range spans {
+			b.WriteString(s[last:span[0]])
+			b.WriteString(r.replacement(rl, s[span[0]:span[1]]))
+			last = span[1]
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s
+}
+
+// spans returns the start and end of every value of s the rule redacts.
+func (rl *rule) spans(s string) [][]int {
+	if rl.find != nil {
+		return rl.find(rl.re, s)
+	}
+	matches := rl.re.FindAllStringIndex(s, -1)
+	if rl.validate == nil {
+		return matches
+	}
+	valid := matches[:0]
+	for _, m := range matches {
+		if rl.validate(s[m[0]:m[1]]) {
+			valid = append(valid, m)
+		}
+	}
+	return valid
+}
+
+func (r *Redactor) replacement(rl *rule, match string) string {
+	rl.count.Add(1)
+	if rl.hash {
+		return fmt.Sprintf("[%s:%s]", rl.name, r.hashValue(match))
+	}
+	return rl.token
+}
+
+// hashValue keeps equal values correlatable without revealing them.
+func (r *Redactor) hashValue(s string) string {
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// digitGroup is a run of digits and the separator before it, 0 for the
+// first group of a match.
+type digitGroup struct {
+	start, end int
+	sep        byte
+}
+
+// cardSpans finds the card numbers within the digit runs matched by re: a
+// single group of 13 to 19 digits, or groups in a card layout joined by one
+// kind of separator, that pass the Luhn check. The longest card starting at
+// each group wins, so a card followed by a CVV, an amount or a date is still
+// found, while longer numbers that only look like cards are left alone.
+func cardSpans(re *regexp.Regexp, s string) [][]int {
+	var spans [][]int
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		var groups []digitGroup
+		for i := m[0]; i < m[1]; {
+			g := digitGroup{start: i}
+			if s[i] == ' ' || s[i] == '-' {
+				g.sep = s[i]
+				g.start++
+			}
+			g.end = g.start
+			for g.end < m[1] && s[g.end] >= '0' && s[g.end] <= '9' {
+				g.end++
+			}
+			groups = append(groups, g)
+			i = g.end
+		}
+
+		for i := 0; i < len(groups); {
+			if n := cardGroups(s, groups[i:]); n > 0 {
+				spans = append(spans, []int{groups[i].start, groups[i+n-1].end})
+				i += n
+				continue
+			}
+			i++
+		}
+	}
+	return spans
+}
+
+// cardGroups returns how many of the leading groups form a card number, 0
+// if none do.
+func cardGroups(s string, groups []digitGroup) int {
+	for n := min(len(groups), 5); n > 0; n-- {
+		if isCardLayout(groups[:n]) && luhnValid(s[groups[0].start:groups[n-1].end]) {
+			return n
+		}
+	}
+	return 0
+}
+
+func isCardLayout(groups []digitGroup) bool {
+	if len(groups) == 1 {
+		size := groups[0].end - groups[0].start
+		return size >= 13 && size <= 19
+	}
+	for _, g := range groups[2:] {
+		if g.sep != groups[1].sep {
+			return false
+		}
+	}
+	for _, layout := range cardLayouts {
+		if len(layout) != len(groups) {
+			continue
+		}
+		matches := true
+		for i, g := range groups {
+			if g.end-g.start != layout[i] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// luhnValid checks the Luhn checksum of a card number, ignoring separators.
+func luhnValid(s string) bool {
+	var digits []int
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,140 @@
+package sampling
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+const defaultServiceField = "service"
+
+type rates struct {
+	levels      map[string]float64
+	defaultRate *float64
+}
+
+func (r rates) lookup(level string) (float64, bool) {
+	if rate, ok := r.levels[strings.ToLower(level)]; ok {
+		return rate, true
+	}
+	if r.defaultRate != nil {
+		return *r.defaultRate, true
+	}
+	return 0, false
+}
+
+// Sampler decides which logs to keep. With a key field, the decision is a
+// hash of the key, so all logs of one request are kept or dropped together
+// and a request kept at a low rate is also kept at every higher rate.
+type Sampler struct {
+	global       rates
+	services     map[string]rates
+	keyField     string
+	serviceField string
+}
+
+// New returns nil when no sampling is configured.
+func New(cfg config.SamplingConfig) (*Sampler, error) {
+	if len(cfg.Levels) == 0 && cfg.DefaultRate == nil && len(cfg.Services) == 0 {
+		return nil, nil
+	}
+
+	s := &Sampler{
+		global:       newRates(cfg.Levels, cfg.DefaultRate),
+		services:     map[string]rates{},
+		keyField:     cfg.KeyField,
+		serviceField: cfg.ServiceField,
+	}
+	if s.serviceField == "" {
+		s.serviceField = defaultServiceField
+	}
+	for service, sc := range cfg.Services {
+		s.services[service] = newRates(sc.Levels, sc.DefaultRate)
+	}
+
+	for _, r := range append([]rates{s.global}, mapValues(s.services)...) {
+		for level, rate := range r.levels {
+			if rate < 0 || rate > 1 {
+				return nil, fmt.Errorf("sample rate of %q must be between 0 and 1", level)
+			}
+		}
+		if r.defaultRate != nil && (*r.defaultRate < 0 || *r.defaultRate > 1) {
+			return nil, fmt.Errorf("default sample rate must be between 0 and 1")
+		}
+	}
+	return s, nil
+}
+
+func newRates(levels map[string]float64, defaultRate *float64) rates {
+	r := rates{levels: map[string]float64{}, defaultRate: defaultRate}
+	for level, rate := range levels {
+		r.levels[strings.ToLower(level)] = rate
+	}
+	return r
+}
+
+func mapValues(m map[string]rates) []rates {
+	var values []rates
+	for _, v := range m {
+		values = append(values, v)
+	}
+	return values
+}
+
+// rate returns the sample rate for logs. Unconfigured levels are kept.
+func (s *Sampler) rate(logs *types.LogFormat) float64 {
+	if service, ok := logs.GetString(s.serviceField); ok {
+		if r, ok := s.services[service]; ok {
+			if rate, ok := r.lookup(logs.Level); ok {
+				return rate
+			}
+		}
+	}
+	if rate, ok := s.global.lookup(logs.Level); ok {
+		return rate
+	}
+	return 1
+}
+
+// Sample reports whether logs is kept and records the rate on it.
+func (s *Sampler) Sample(logs *types.LogFormat) bool {
+	rate := s.rate(logs)
+	if rate >= 1 {
+		logs.SampleRate = 1
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+
+	var draw float64
+	if key, ok := logs.GetString(s.keyField); ok && s.keyField != "" {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		draw = float64(mix(h.Sum64())) / math.MaxUint64
+	} else {
+		draw = rand.Float64()
+	}
+
+	if draw >= rate {
+		return false
+	}
+	logs.SampleRate = rate
+	return true
+}
+
+// mix spreads FNV's poorly distributed high bits for short, similar keys
+// such as sequential request IDs.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package shipper
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns the inode of a file, used to detect rotation.
+func fileID(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
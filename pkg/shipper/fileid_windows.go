@@ -0,0 +1,10 @@
+//go:build windows
+
+package shipper
+
+import "os"
+
+// fileID is not available on windows; rotation is detected by truncation only.
+func fileID(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,69 @@
+package shipper
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// RegistryEntry is the committed read position of a single file.
+type RegistryEntry struct {
+	FileID uint64 `json:"file_id"`
+	Offset int64  `json:"offset"`
+}
+
+// Registry persists read offsets so shipping resumes where it stopped.
+type Registry struct {
+	path    string
+	entries map[string]RegistryEntry
+}
+
+func LoadRegistry(path string) (*Registry, error) {
+	reg := &Registry{
+		path:    path,
+		entries: map[string]RegistryEntry{},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return reg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &reg.entries); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+func (reg *Registry) Get(path string) (RegistryEntry, bool) {
+	entry, ok := reg.entries[path]
+	return entry, ok
+}
+
+func (reg *Registry) Set(path string, entry RegistryEntry) {
+	reg.entries[path] = entry
+}
+
+func (reg *Registry) Delete(path string) {
+	delete(reg.entries, path)
+}
+
+// Save writes the registry atomically through a temporary file.
+func (reg *Registry) Save() error {
+	data, err := json.MarshalIndent(reg.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(reg.path), 0o755); err != nil {
+		return err
+	}
+	tmp := reg.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, reg.path)
+}
@@ -0,0 +1,50 @@
+package shipper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistrySaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "registry.json")
+
+	reg, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry of a missing file: %v", err)
+	}
+	if _, ok := reg.Get("/var/log/app.log"); ok {
+		t.Fatal("new registry has entries")
+	}
+
+	reg.Set("/var/log/app.log", RegistryEntry{FileID: 42, Offset: 1024})
+	reg.Set("/var/log/gone.log", RegistryEntry{FileID: 7, Offset: 1})
+	reg.Delete("/var/log/gone.log")
+	if err := reg.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	loaded, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry: %v", err)
+	}
+	if entry, ok := loaded.Get("/var/log/app.log"); !ok || entry != (RegistryEntry{FileID: 42, Offset: 1024}) {
+		t.Errorf("entry = %+v, %v", entry, ok)
+	}
+	if _, ok := loaded.Get("/var/log/gone.log"); ok {
+		t.Error("deleted entry was saved")
+	}
+}
+
+func TestLoadRegistryCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRegistry(path); err == nil {
+		t.Fatal("LoadRegistry of a corrupt file succeeded")
+	}
+}
@@ -0,0 +1,73 @@
+package shipper
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/tlsconfig"
+	"github.com/spf13/cobra"
+)
+
+func Run(cmd *cobra.Command, args []string) {
+	paths, _ := cmd.Flags().GetStringSlice("path")
+	format, _ := cmd.Flags().GetString("format")
+	configFile, _ := cmd.Flags().GetString("config")
+	target, _ := cmd.Flags().GetString("target")
+	apiKey, _ := cmd.Flags().GetString("api-key")
+	tlsCA, _ := cmd.Flags().GetString("tls-ca")
+	tlsCert, _ := cmd.Flags().GetString("tls-cert")
+	tlsKey, _ := cmd.Flags().GetString("tls-key")
+	registry, _ := cmd.Flags().GetString("registry")
+	batchSize, _ := cmd.Flags().GetInt("batch-size")
+	flushInterval, _ := cmd.Flags().GetDuration("flush-interval")
+	scanInterval, _ := cmd.Flags().GetDuration("scan-interval")
+
+	fileCfg, err := config.Load(configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	var sources []Source
+	for _, sc := range fileCfg.Shipper.Sources {
+		sources = append(sources, Source{Name: sc.Name, Paths: sc.Paths, Format: sc.Format, Multiline: sc.Multiline})
+	}
+	if len(paths) > 0 {
+		sources = append(sources, Source{Paths: paths, Format: format})
+	}
+	if len(sources) == 0 {
+		log.Fatal("At least one --path or configured shipper source is required")
+	}
+
+	cfg := Config{
+		Sources:       sources,
+		Target:        target,
+		APIKey:        apiKey,
+		TLS: tlsconfig.ClientOptions{
+			CAFile:   tlsCA,
+			CertFile: tlsCert,
+			KeyFile:  tlsKey,
+		},
+		RegistryPath:  registry,
+		BatchSize:     batchSize,
+		FlushInterval: flushInterval,
+		ScanInterval:  scanInterval,
+	}
+
+	shipper, err := NewShipper(cfg)
+	if err != nil {
+		log.Fatalf("Failed to start shipper: %v", err)
+	}
+
+	//Catch kill signal for graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Shipping %d sources to %v", len(sources), target)
+	if err := shipper.Run(ctx); err != nil {
+		log.Fatalf("Shipper stopped: %v", err)
+	}
+	log.Println("Shipper stopped.")
+}
@@ -0,0 +1,170 @@
+package shipper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/tlsconfig"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+const (
+	bulkPath       = "/api/v1/log/bulk"
+	initialBackoff = time.Second
+	maxBackoff     = 30 * time.Second
+)
+
+// errUnauthorized is returned when the server refuses the shipper itself, which
+// no retry can fix.
+var errUnauthorized = errors.New("shipper is not allowed to ingest")
+
+// statusError is an unexpected answer of the bulk endpoint.
+type statusError struct {
+	code       int
+	status     string
+	message    []byte
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %s: %s", e.status, e.message)
+}
+
+// retryable reports whether sending the same batch again may succeed:
+// network errors, 408, 429 and 5xx answers.
+func retryable(err error) bool {
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	switch {
+	case statusErr.code == http.StatusRequestTimeout, statusErr.code == http.StatusTooManyRequests:
+		return true
+	default:
+		return statusErr.code >= 500
+	}
+}
+
+type sender struct {
+	url    string
+	apiKey string
+	client *http.Client
+	// backoff is the first wait before a retry, doubled up to maxBackoff.
+	backoff time.Duration
+}
+
+func newSender(target, apiKey string, tlsOpts tlsconfig.ClientOptions) (*sender, error) {
+	tlsConfig, err := tlsconfig.Client(tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &sender{
+		url:     strings.TrimRight(target, "/") + bulkPath,
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 30 * time.Second, Transport: transport},
+		backoff: initialBackoff,
+	}, nil
+}
+
+// send posts logs as NDJSON under the idempotency key. It retries network
+// errors, 429 and 5xx answers with backoff until the server accepts every
+// line or ctx is done. A batch answered with 413 is split in halves, sent
+// under derived keys. Other 4xx answers reject the batch for good: 401 and
+// 403 return errUnauthorized, any other is logged and the batch dropped.
+func (s *sender) send(ctx context.Context, key string, logs []types.LogFormat) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, lg := range logs {
+		if err := encoder.Encode(lg); err != nil {
+			return err
+		}
+	}
+
+	backoff := s.backoff
+	for {
+		err := s.post(ctx, key, body.Bytes())
+		if err == nil {
+			return nil
+		}
+
+		var statusErr *statusError
+		errors.As(err, &statusErr)
+		switch {
+		case statusErr != nil && statusErr.code == http.StatusRequestEntityTooLarge && len(logs) > 1:
+			half := len(logs) / 2
+			log.Printf("Batch of %d lines is too large for %v, splitting it", len(logs), s.url)
+			if err := s.send(ctx, key+"-1", logs[:half]); err != nil {
+				return err
+			}
+			return s.send(ctx, key+"-2", logs[half:])
+		case statusErr != nil && (statusErr.code == http.StatusUnauthorized || statusErr.code == http.StatusForbidden):
+			return fmt.Errorf("%w: %v", errUnauthorized, err)
+		case !retryable(err):
+			log.Printf("Dropping %d lines rejected by %v. Error: %v", len(logs), s.url, err)
+			return nil
+		}
+
+		wait := backoff
+		if statusErr != nil && statusErr.retryAfter > wait {
+			wait = statusErr.retryAfter
+		}
+		log.Printf("Cannot send batch to %v, retrying in %v. Error: %v", s.url, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (s *sender) post(ctx context.Context, key string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if key != "" {
+		req.Header.Set(types.IdempotencyKeyHeader, key)
+	}
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		statusErr := &statusError{code: resp.StatusCode, status: resp.Status, message: bytes.TrimSpace(msg)}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			statusErr.retryAfter = min(time.Duration(seconds)*time.Second, maxBackoff)
+		}
+		return statusErr
+	}
+
+	// the lines are JSON written above, so failed lines were not rejected
+	// for their content and are retried rather than committed
+	var result types.BulkResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err == nil && result.Failed > 0 {
+		return fmt.Errorf("server failed %d of %d lines", result.Failed, result.Failed+result.Accepted)
+	}
+	return nil
+}
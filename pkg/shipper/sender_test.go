@@ -0,0 +1,178 @@
+package shipper
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/tlsconfig"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+// bulkServer records the requests of a sender and answers each with the
+// status returned by respond.
+type bulkServer struct {
+	mu       sync.Mutex
+	requests []bulkRequest
+	respond  func(attempt int, lines []string) int
+}
+
+type bulkRequest struct {
+	key   string
+	lines []string
+}
+
+func (b *bulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var lines []string
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	b.mu.Lock()
+	b.requests = append(b.requests, bulkRequest{key: r.Header.Get(types.IdempotencyKeyHeader), lines: lines})
+	attempt := len(b.requests)
+	b.mu.Unlock()
+
+	code := http.StatusOK
+	if b.respond != nil {
+		code = b.respond(attempt, lines)
+	}
+	if code == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "0")
+	}
+	if code != http.StatusOK {
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	w.Write([]byte(`{"accepted": 1, "failed": 0}`))
+}
+
+func (b *bulkServer) received() []bulkRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]bulkRequest(nil), b.requests...)
+}
+
+func newTestSender(t *testing.T, b *bulkServer) *sender {
+	t.Helper()
+	server := httptest.NewServer(b)
+	t.Cleanup(server.Close)
+	s, err := newSender(server.URL, "", tlsconfig.ClientOptions{})
+	if err != nil {
+		t.Fatalf("newSender: %v", err)
+	}
+	s.backoff = time.Millisecond
+	return s
+}
+
+func testLogs(n int) []types.LogFormat {
+	logs := make([]types.LogFormat, n)
+	for i := range logs {
+		logs[i] = types.LogFormat{Level: "info", Message: string(rune('a' + i))}
+	}
+	return logs
+}
+
+func TestSendStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		respond  func(attempt int, lines []string) int
+		err      error
+		attempts int
+	}{
+		{"accepted", nil, nil, 1},
+		{"server error is retried", func(attempt int, _ []string) int {
+			if attempt < 3 {
+				return http.StatusServiceUnavailable
+			}
+			return http.StatusOK
+		}, nil, 3},
+		{"rate limit is retried", func(attempt int, _ []string) int {
+			if attempt == 1 {
+				return http.StatusTooManyRequests
+			}
+			return http.StatusOK
+		}, nil, 2},
+		{"bad request is dropped", func(int, []string) int { return http.StatusBadRequest }, nil, 1},
+		{"unauthorized stops", func(int, []string) int { return http.StatusUnauthorized }, errUnauthorized, 1},
+		{"forbidden stops", func(int, []string) int { return http.StatusForbidden }, errUnauthorized, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &bulkServer{respond: tt.respond}
+			err := newTestSender(t, b).send(context.Background(), "key", testLogs(2))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("send error = %v, want %v", err, tt.err)
+			}
+			requests := b.received()
+			if len(requests) != tt.attempts {
+				t.Fatalf("attempts = %d, want %d", len(requests), tt.attempts)
+			}
+			for _, req := range requests {
+				if req.key != "key" {
+					t.Errorf("idempotency key = %q, want key on every attempt", req.key)
+				}
+			}
+		})
+	}
+}
+
+func TestSendSplitsTooLargeBatch(t *testing.T) {
+	b := &bulkServer{respond: func(_ int, lines []string) int {
+		if len(lines) > 2 {
+			return http.StatusRequestEntityTooLarge
+		}
+		return http.StatusOK
+	}}
+	if err := newTestSender(t, b).send(context.Background(), "key", testLogs(5)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	accepted := map[string]int{}
+	keys := map[string]bool{}
+	for _, req := range b.received() {
+		if len(req.lines) > 2 {
+			continue
+		}
+		if keys[req.key] {
+			t.Errorf("key %q used for two parts", req.key)
+		}
+		keys[req.key] = true
+		for _, line := range req.lines {
+			accepted[line]++
+		}
+	}
+	if len(accepted) != 5 {
+		t.Fatalf("accepted %d distinct lines, want 5: %v", len(accepted), accepted)
+	}
+	for line, n := range accepted {
+		if n != 1 {
+			t.Errorf("line %v accepted %d times", line, n)
+		}
+	}
+}
+
+func TestSendDropsSingleTooLargeLine(t *testing.T) {
+	b := &bulkServer{respond: func(int, []string) int { return http.StatusRequestEntityTooLarge }}
+	if err := newTestSender(t, b).send(context.Background(), "key", testLogs(1)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if n := len(b.received()); n != 1 {
+		t.Fatalf("attempts = %d, want 1", n)
+	}
+}
+
+func TestSendStopsWithContext(t *testing.T) {
+	b := &bulkServer{respond: func(int, []string) int { return http.StatusServiceUnavailable }}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := newTestSender(t, b).send(ctx, "key", testLogs(1)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("send error = %v, want deadline exceeded", err)
+	}
+}
@@ -0,0 +1,307 @@
+package shipper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/config"
+	"github.com/adiyakaihsan/go-logger/pkg/multiline"
+	"github.com/adiyakaihsan/go-logger/pkg/parser"
+	"github.com/adiyakaihsan/go-logger/pkg/tlsconfig"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+type Config struct {
+	Sources       []Source
+	Target        string
+	APIKey        string
+	TLS           tlsconfig.ClientOptions
+	RegistryPath  string
+	BatchSize     int
+	FlushInterval time.Duration
+	ScanInterval  time.Duration
+}
+
+// Source is a set of file globs whose lines are parsed with Format and,
+// optionally, merged by Multiline.
+type Source struct {
+	Name      string
+	Paths     []string
+	Format    string
+	Multiline *config.MultilineConfig
+
+	parser     parser.Parser
+	aggregator *multiline.Aggregator
+}
+
+// record is a parsed log waiting to be shipped, with the file position to
+// commit once it is.
+type record struct {
+	line line
+	logs types.LogFormat
+}
+
+// Shipper tails files matched by glob patterns and sends their lines in
+// batches to the bulk endpoint of a go-logger server or proxy.
+//
+// Delivery is at least once: offsets are committed after the server accepts
+// a batch, so a batch is resent when its answer is lost or the shipper stops
+// before committing. Each batch carries an idempotency key derived from its
+// file offsets, which lets the server skip a resent batch it still
+// remembers; a batch resent after a server restart is indexed twice.
+type Shipper struct {
+	cfg      Config
+	registry *Registry
+	sender   *sender
+	tailers  map[string]*tailer
+	hostname string
+
+	batch      []record
+	batchStart time.Time
+	// err stops the shipper once the server refuses it.
+	err error
+}
+
+func NewShipper(cfg Config) (*Shipper, error) {
+	hostname, _ := os.Hostname()
+
+	sender, err := newSender(cfg.Target, cfg.APIKey, cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure TLS: %w", err)
+	}
+
+	s := &Shipper{
+		cfg:      cfg,
+		sender:   sender,
+		tailers:  map[string]*tailer{},
+		hostname: hostname,
+	}
+
+	for i := range s.cfg.Sources {
+		source := &s.cfg.Sources[i]
+		p, err := parser.Get(source.Format)
+		if err != nil {
+			return nil, fmt.Errorf("source %v: %w", source.Name, err)
+		}
+		source.parser = p
+
+		if source.Multiline != nil {
+			source.aggregator, err = multiline.New(*source.Multiline, func(_ string, logs types.LogFormat, ref interface{}) error {
+				s.append(record{line: ref.(line), logs: logs})
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("source %v: %w", source.Name, err)
+			}
+		}
+	}
+
+	registry, err := LoadRegistry(cfg.RegistryPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registry: %w", err)
+	}
+	s.registry = registry
+
+	return s, nil
+}
+
+// Run tails files until ctx is cancelled, then ships what is left.
+func (s *Shipper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.cfg.ScanInterval)
+	defer ticker.Stop()
+	defer s.closeTailers()
+
+	for {
+		s.scan()
+		s.poll(ctx)
+		s.flushMultiline(false)
+
+		if len(s.batch) > 0 && time.Since(s.batchStart) >= s.cfg.FlushInterval {
+			if err := s.flush(ctx); err != nil {
+				s.shipError(err)
+			}
+		}
+		if s.err != nil {
+			return s.err
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Shipping remaining lines before exit.")
+			s.flushMultiline(true)
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.FlushInterval)
+			defer cancel()
+			return s.flush(shutdownCtx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan opens tailers for newly matched files.
+func (s *Shipper) scan() {
+	for i := range s.cfg.Sources {
+		source := &s.cfg.Sources[i]
+		for _, pattern := range source.Paths {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				log.Printf("Invalid path pattern %v. Error: %v", pattern, err)
+				continue
+			}
+			for _, path := range matches {
+				if _, ok := s.tailers[path]; ok {
+					continue
+				}
+				entry, hasEntry := s.registry.Get(path)
+				t, err := openTailer(path, source, entry, hasEntry)
+				if err != nil {
+					log.Printf("Cannot open %v. Error: %v", path, err)
+					continue
+				}
+				log.Printf("Tailing %v from offset %d", path, t.offset)
+				s.tailers[path] = t
+			}
+		}
+	}
+}
+
+func (s *Shipper) poll(ctx context.Context) {
+	for path, t := range s.tailers {
+		alive, err := t.poll(s.add)
+		if err != nil {
+			log.Printf("Cannot read %v. Error: %v", path, err)
+		}
+		if !alive {
+			log.Printf("Stopped tailing removed file %v", path)
+			t.close()
+			delete(s.tailers, path)
+		}
+
+		if len(s.batch) >= s.cfg.BatchSize && s.err == nil {
+			if err := s.flush(ctx); err != nil {
+				s.shipError(err)
+			}
+		}
+	}
+}
+
+// shipError logs a failed flush. A refusal of the shipper stops it, since
+// every later batch would be refused too.
+func (s *Shipper) shipError(err error) {
+	log.Printf("Cannot ship batch. Error: %v", err)
+	if errors.Is(err, errUnauthorized) {
+		s.err = err
+	}
+}
+
+// add parses a line and passes it through the source's multiline aggregator.
+func (s *Shipper) add(l line) {
+	logs := s.parseLine(l)
+	if l.source.aggregator != nil {
+		l.source.aggregator.Add(l.path, logs, l)
+		return
+	}
+	s.append(record{line: l, logs: logs})
+}
+
+func (s *Shipper) append(rec record) {
+	if len(s.batch) == 0 {
+		s.batchStart = time.Now()
+	}
+	s.batch = append(s.batch, rec)
+}
+
+// flushMultiline moves merged events into the batch, either those past their
+// flush timeout or, when all is set, every pending one.
+func (s *Shipper) flushMultiline(all bool) {
+	for i := range s.cfg.Sources {
+		aggregator := s.cfg.Sources[i].aggregator
+		switch {
+		case aggregator == nil:
+		case all:
+			aggregator.Flush()
+		default:
+			aggregator.FlushExpired()
+		}
+	}
+}
+
+// flush sends the pending batch and commits its offsets to the registry.
+func (s *Shipper) flush(ctx context.Context) error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+
+	logs := make([]types.LogFormat, 0, len(s.batch))
+	for _, rec := range s.batch {
+		logs = append(logs, rec.logs)
+	}
+
+	if err := s.sender.send(ctx, s.batchKey(), logs); err != nil {
+		return err
+	}
+
+	for _, rec := range s.batch {
+		s.registry.Set(rec.line.path, RegistryEntry{FileID: rec.line.fileID, Offset: rec.line.offset})
+	}
+	if err := s.registry.Save(); err != nil {
+		log.Printf("Cannot save registry. Error: %v", err)
+	}
+	log.Printf("Shipped %d logs", len(s.batch))
+
+	s.batch = s.batch[:0]
+	return nil
+}
+
+// batchKey derives the idempotency key of the pending batch from the file
+// positions of its lines, so a resent batch carries the same key.
+func (s *Shipper) batchKey() string {
+	h := sha256.New()
+	h.Write([]byte(s.hostname))
+	for _, rec := range s.batch {
+		fmt.Fprintf(h, "\x00%s\x00%d\x00%d", rec.line.path, rec.line.fileID, rec.line.offset)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// parseLine parses a line with its source's format. Lines that do not parse
+// are shipped as a plain message.
+func (s *Shipper) parseLine(l line) types.LogFormat {
+	logs, err := l.source.parser.Parse(l.text)
+	if err != nil {
+		logs = types.LogFormat{
+			Timestamp: time.Now().UTC(),
+			Level:     "info",
+			Message:   l.text,
+		}
+		if l.source.Format != "" {
+			logs.Fields = map[string]interface{}{"parse_error": err.Error()}
+		}
+	}
+	if logs.Timestamp.IsZero() {
+		logs.Timestamp = time.Now().UTC()
+	}
+	if logs.Fields == nil {
+		logs.Fields = map[string]interface{}{}
+	}
+	logs.Fields["file"] = l.path
+	logs.Fields["host"] = s.hostname
+	if l.source.Name != "" {
+		logs.Fields["source"] = l.source.Name
+	}
+
+	return logs
+}
+
+func (s *Shipper) closeTailers() {
+	for path, t := range s.tailers {
+		t.close()
+		delete(s.tailers, path)
+	}
+}
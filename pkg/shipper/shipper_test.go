@@ -0,0 +1,143 @@
+package shipper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestShipper(t *testing.T, target, dir string) *Shipper {
+	t.Helper()
+	s, err := NewShipper(Config{
+		Sources:       []Source{{Paths: []string{filepath.Join(dir, "*.log")}}},
+		Target:        target,
+		RegistryPath:  filepath.Join(dir, "registry.json"),
+		BatchSize:     100,
+		FlushInterval: 10 * time.Millisecond,
+		ScanInterval:  5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewShipper: %v", err)
+	}
+	s.sender.backoff = time.Millisecond
+	return s
+}
+
+// shipUntil runs a shipper until the server received want lines in total.
+func shipUntil(t *testing.T, s *Shipper, b *bulkServer, want int) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for countLines(b) < want && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := countLines(b); got != want {
+		t.Fatalf("server received %d lines, want %d", got, want)
+	}
+}
+
+func countLines(b *bulkServer) int {
+	n := 0
+	for _, req := range b.received() {
+		n += len(req.lines)
+	}
+	return n
+}
+
+func TestShipperResumesFromRegistry(t *testing.T) {
+	b := &bulkServer{}
+	server := httptest.NewServer(b)
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "one\ntwo\n")
+	shipUntil(t, newTestShipper(t, server.URL, dir), b, 2)
+
+	reg, err := LoadRegistry(filepath.Join(dir, "registry.json"))
+	if err != nil {
+		t.Fatalf("LoadRegistry: %v", err)
+	}
+	if entry, ok := reg.Get(path); !ok || entry.Offset != int64(len("one\ntwo\n")) {
+		t.Fatalf("registry entry = %+v, %v", entry, ok)
+	}
+
+	// a new shipper only sends what was appended since
+	appendFile(t, path, "three\n")
+	shipUntil(t, newTestShipper(t, server.URL, dir), b, 3)
+	requests := b.received()
+	last := requests[len(requests)-1]
+	if len(last.lines) != 1 || !strings.Contains(last.lines[0], `"three"`) {
+		t.Fatalf("last batch = %q, want only three", last.lines)
+	}
+}
+
+func TestShipperRetriesUntilAccepted(t *testing.T) {
+	b := &bulkServer{respond: func(attempt int, _ []string) int {
+		if attempt < 3 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	}}
+	server := httptest.NewServer(b)
+	defer server.Close()
+
+	dir := t.TempDir()
+	appendFile(t, filepath.Join(dir, "app.log"), "one\n")
+	s := newTestShipper(t, server.URL, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(b.received()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	requests := b.received()
+	if len(requests) != 3 {
+		t.Fatalf("attempts = %d, want 3", len(requests))
+	}
+	for _, req := range requests[1:] {
+		if req.key != requests[0].key || req.key == "" {
+			t.Errorf("retry key %q, want %q", req.key, requests[0].key)
+		}
+	}
+}
+
+func TestShipperStopsWhenRefused(t *testing.T) {
+	b := &bulkServer{respond: func(int, []string) int { return http.StatusUnauthorized }}
+	server := httptest.NewServer(b)
+	defer server.Close()
+
+	dir := t.TempDir()
+	appendFile(t, filepath.Join(dir, "app.log"), "one\n")
+	s := newTestShipper(t, server.URL, dir)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, errUnauthorized) {
+			t.Fatalf("Run error = %v, want errUnauthorized", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shipper kept running after 401")
+	}
+}
@@ -0,0 +1,165 @@
+package shipper
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+const maxLineSize = 1 << 20
+
+// line is a complete line read from a file together with the offset just
+// after it, which is what gets committed to the registry once shipped.
+type line struct {
+	source *Source
+	path   string
+	fileID uint64
+	offset int64
+	text   string
+}
+
+type tailer struct {
+	source  *Source
+	path    string
+	file    *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+	buf     []byte
+}
+
+// openTailer opens path and resumes from the registry entry when it still
+// refers to the same file.
+func openTailer(path string, source *Source, entry RegistryEntry, hasEntry bool) (*tailer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	t := &tailer{
+		source: source,
+		path:   path,
+		file:   file,
+		info:   info,
+		buf:    make([]byte, 32*1024),
+	}
+
+	if hasEntry && entry.FileID == fileID(info) && entry.Offset <= info.Size() {
+		if _, err := file.Seek(entry.Offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+		t.offset = entry.Offset
+	}
+
+	return t, nil
+}
+
+func (t *tailer) id() uint64 {
+	return fileID(t.info)
+}
+
+// read emits every complete line appended since the last call.
+func (t *tailer) read(emit func(line)) error {
+	for {
+		n, err := t.file.Read(t.buf)
+		if n > 0 {
+			t.partial = append(t.partial, t.buf[:n]...)
+			t.emitLines(emit)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (t *tailer) emitLines(emit func(line)) {
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			if len(t.partial) >= maxLineSize {
+				t.emitPartial(emit)
+			}
+			return
+		}
+		t.offset += int64(i + 1)
+		text := string(bytes.TrimRight(t.partial[:i], "\r"))
+		t.partial = t.partial[i+1:]
+		if text != "" {
+			emit(line{source: t.source, path: t.path, fileID: t.id(), offset: t.offset, text: text})
+		}
+	}
+}
+
+// emitPartial flushes an unterminated line, used when a file is rotated away
+// or a line grows beyond maxLineSize.
+func (t *tailer) emitPartial(emit func(line)) {
+	if len(t.partial) == 0 {
+		return
+	}
+	t.offset += int64(len(t.partial))
+	emit(line{source: t.source, path: t.path, fileID: t.id(), offset: t.offset, text: string(t.partial)})
+	t.partial = nil
+}
+
+// poll reads new data and handles truncation and rotation of the path.
+// It returns false once the file is gone and fully drained.
+func (t *tailer) poll(emit func(line)) (bool, error) {
+	if err := t.read(emit); err != nil {
+		return true, err
+	}
+
+	current, err := os.Stat(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.emitPartial(emit)
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	if !os.SameFile(current, t.info) {
+		// rotated: the old handle was drained above, continue with the new file
+		t.emitPartial(emit)
+		file, err := os.Open(t.path)
+		if err != nil {
+			return true, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return true, err
+		}
+		t.file.Close()
+		t.file = file
+		t.info = info
+		t.offset = 0
+		return true, t.read(emit)
+	}
+
+	if current.Size() < t.offset {
+		// truncated in place: start over from the beginning
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return true, err
+		}
+		t.info = current
+		t.offset = 0
+		t.partial = nil
+		return true, t.read(emit)
+	}
+
+	return true, nil
+}
+
+func (t *tailer) close() {
+	t.file.Close()
+}
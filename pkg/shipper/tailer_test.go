@@ -0,0 +1,155 @@
+package shipper
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pollLines polls t once and returns the text of the lines read.
+func pollLines(t *testing.T, tl *tailer) ([]string, bool) {
+	t.Helper()
+	var texts []string
+	alive, err := tl.poll(func(l line) {
+		texts = append(texts, l.text)
+	})
+	if err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	return texts, alive
+}
+
+func openTestTailer(t *testing.T, path string, entry RegistryEntry, hasEntry bool) *tailer {
+	t.Helper()
+	tl, err := openTailer(path, &Source{}, entry, hasEntry)
+	if err != nil {
+		t.Fatalf("openTailer: %v", err)
+	}
+	t.Cleanup(tl.close)
+	return tl
+}
+
+func TestTailerLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "one\r\n\ntwo\nthr")
+	tl := openTestTailer(t, path, RegistryEntry{}, false)
+
+	var offsets []int64
+	if _, err := tl.poll(func(l line) { offsets = append(offsets, l.offset) }); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if want := []int64{5, 10}; !reflect.DeepEqual(offsets, want) {
+		t.Fatalf("offsets = %v, want %v", offsets, want)
+	}
+
+	// the partial line is held until it is terminated
+	appendFile(t, path, "ee\n")
+	if got, _ := pollLines(t, tl); !reflect.DeepEqual(got, []string{"three"}) {
+		t.Fatalf("lines = %q, want [three]", got)
+	}
+}
+
+func TestTailerResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "one\ntwo\n")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		entry    RegistryEntry
+		hasEntry bool
+		want     []string
+	}{
+		{"no entry", RegistryEntry{}, false, []string{"one", "two"}},
+		{"same file", RegistryEntry{FileID: fileID(info), Offset: 4}, true, []string{"two"}},
+		{"other file", RegistryEntry{FileID: fileID(info) + 1, Offset: 4}, true, []string{"one", "two"}},
+		{"offset past the end", RegistryEntry{FileID: fileID(info), Offset: 100}, true, []string{"one", "two"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := openTestTailer(t, path, tt.entry, tt.hasEntry)
+			if got, _ := pollLines(t, tl); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTailerTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "first line\nsecond line\n")
+	tl := openTestTailer(t, path, RegistryEntry{}, false)
+	pollLines(t, tl)
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "new\n")
+	got, alive := pollLines(t, tl)
+	if !alive || !reflect.DeepEqual(got, []string{"new"}) {
+		t.Fatalf("lines = %q alive = %v, want [new] true", got, alive)
+	}
+	if tl.offset != 4 {
+		t.Errorf("offset = %d, want 4", tl.offset)
+	}
+}
+
+func TestTailerRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "old one\n")
+	tl := openTestTailer(t, path, RegistryEntry{}, false)
+	pollLines(t, tl)
+
+	// lines written to the old file before rotation are still read,
+	// including an unterminated last line
+	appendFile(t, path, "old two\nold tail")
+	if err := os.Rename(path, filepath.Join(dir, "app.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "new one\n")
+
+	got, alive := pollLines(t, tl)
+	if want := []string{"old two", "old tail", "new one"}; !alive || !reflect.DeepEqual(got, want) {
+		t.Fatalf("lines = %q alive = %v, want %q true", got, alive, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tl.id() != fileID(info) || tl.offset != int64(len("new one\n")) {
+		t.Errorf("tailer at file %d offset %d, want the new file at %d", tl.id(), tl.offset, len("new one\n"))
+	}
+}
+
+func TestTailerRemoved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "one\n")
+	tl := openTestTailer(t, path, RegistryEntry{}, false)
+	pollLines(t, tl)
+
+	appendFile(t, path, "last")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	got, alive := pollLines(t, tl)
+	if alive || !reflect.DeepEqual(got, []string{"last"}) {
+		t.Fatalf("lines = %q alive = %v, want [last] false", got, alive)
+	}
+}
@@ -0,0 +1,179 @@
+// Package sloghandler is a log/slog Handler that ships records to go-logger
+// through pkg/client:
+//
+//	c, err := client.New(client.Config{URL: "http://localhost:8080"})
+//	...
+//	defer c.Close(context.Background())
+//	logger := slog.New(sloghandler.New(c, nil))
+//	logger.Info("order placed", "order_id", 42, slog.Group("req", "method", "POST"))
+//
+// Attributes become structured fields; groups are flattened into dotted
+// names such as "req.method".
+package sloghandler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/client"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+type Options struct {
+	// Level is the minimum level shipped. It defaults to slog.LevelInfo.
+	Level slog.Leveler
+	// AddSource records the caller as source.file, source.line and
+	// source.function.
+	AddSource bool
+}
+
+type Handler struct {
+	client *client.Client
+	opts   Options
+	// fields holds attributes added by WithAttrs, prefix the open groups.
+	fields map[string]interface{}
+	prefix string
+}
+
+func New(c *client.Client, opts *Options) *Handler {
+	h := &Handler{client: c, fields: map[string]interface{}{}}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	return h
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle buffers the record in the client. It only fails when the client
+// is closed or drops the record.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	logs := types.LogFormat{
+		Timestamp: r.Time,
+		Level:     levelName(r.Level),
+		Message:   r.Message,
+		Fields:    make(map[string]interface{}, len(h.fields)+r.NumAttrs()),
+	}
+	if logs.Timestamp.IsZero() {
+		logs.Timestamp = time.Now()
+	}
+	for name, value := range h.fields {
+		logs.Fields[name] = value
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(logs.Fields, h.prefix, a)
+		return true
+	})
+
+	if h.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		logs.Fields["source.file"] = frame.File
+		logs.Fields["source.line"] = frame.Line
+		logs.Fields["source.function"] = frame.Function
+	}
+
+	if len(logs.Fields) == 0 {
+		logs.Fields = nil
+	}
+	return h.client.Log(logs)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := h.clone()
+	for _, a := range attrs {
+		addAttr(h2.fields, h2.prefix, a)
+	}
+	return h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := h.clone()
+	h2.prefix += name + "."
+	return h2
+}
+
+func (h *Handler) clone() *Handler {
+	h2 := *h
+	h2.fields = make(map[string]interface{}, len(h.fields))
+	for name, value := range h.fields {
+		h2.fields[name] = value
+	}
+	return &h2
+}
+
+// addAttr stores a under prefix, flattening groups. Empty attributes are
+// skipped and groups without a key are inlined, as slog handlers must.
+func addAttr(fields map[string]interface{}, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addAttr(fields, prefix, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	fields[prefix+a.Key] = value(a.Value)
+}
+
+// value converts v to what encodes as JSON: times, durations and errors
+// become strings, as do NaN and infinite floats and any value JSON cannot
+// encode, such as channels or functions.
+func value(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindFloat64:
+		if f := v.Float64(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return v.String()
+		}
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+		if _, err := json.Marshal(v.Any()); err != nil {
+			return v.String()
+		}
+	}
+	return v.Any()
+}
+
+// levelName maps slog levels onto the level names used by go-logger.
+// Custom levels fall into the nearest standard level below them.
+func levelName(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "error"
+	case level >= slog.LevelWarn:
+		return "warn"
+	case level >= slog.LevelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
@@ -0,0 +1,130 @@
+package sloghandler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adiyakaihsan/go-logger/pkg/client"
+	"github.com/adiyakaihsan/go-logger/pkg/types"
+)
+
+type stringer struct{}
+
+func (stringer) String() string { return "stringer" }
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		name string
+		in   slog.Value
+		want interface{}
+	}{
+		{"string", slog.StringValue("a"), "a"},
+		{"int", slog.IntValue(42), int64(42)},
+		{"float", slog.Float64Value(1.5), 1.5},
+		{"nan", slog.Float64Value(math.NaN()), "NaN"},
+		{"infinity", slog.Float64Value(math.Inf(-1)), "-Inf"},
+		{"duration", slog.DurationValue(1500 * time.Millisecond), "1.5s"},
+		{"time", slog.TimeValue(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)), "2024-01-02T03:04:05Z"},
+		{"error", slog.AnyValue(errors.New("boom")), "boom"},
+		{"json value", slog.AnyValue([]int{1, 2}), []int{1, 2}},
+		{"channel", slog.AnyValue(make(chan int)), ""},
+		{"function", slog.AnyValue(func() {}), ""},
+		{"nan in a map", slog.AnyValue(map[string]float64{"x": math.NaN()}), "map[x:NaN]"},
+		{"stringer without json", slog.AnyValue(stringer{}), stringer{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := value(tt.in)
+			if tt.want == "" {
+				if _, ok := got.(string); !ok {
+					t.Fatalf("value = %#v, want a string", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("value = %#v, want %#v", got, tt.want)
+			}
+			if _, err := json.Marshal(got); err != nil {
+				t.Errorf("value %#v does not encode: %v", got, err)
+			}
+		})
+	}
+}
+
+// bulkServer collects the logs posted to it.
+type bulkServer struct {
+	mu   sync.Mutex
+	logs []types.LogFormat
+}
+
+func (b *bulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var result types.BulkResult
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var lg types.LogFormat
+		if err := json.Unmarshal(scanner.Bytes(), &lg); err != nil {
+			result.Failed++
+			continue
+		}
+		b.logs = append(b.logs, lg)
+		result.Accepted++
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func TestHandler(t *testing.T) {
+	b := &bulkServer{}
+	server := httptest.NewServer(b)
+	defer server.Close()
+
+	c, err := client.New(client.Config{URL: server.URL, DisableGzip: true, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("client.New: %v", err)
+	}
+	logger := slog.New(New(c, &Options{Level: slog.LevelInfo}))
+
+	logger.Debug("not shipped")
+	logger.With("service", "api").WithGroup("req").Warn("slow request",
+		"method", "POST",
+		slog.Group("db", "ratio", math.NaN()),
+		slog.Group("", "inlined", true),
+		"done", make(chan struct{}),
+	)
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if len(b.logs) != 1 {
+		t.Fatalf("server received %d logs, want 1", len(b.logs))
+	}
+	got := b.logs[0]
+	if got.Level != "warn" || got.Message != "slow request" {
+		t.Errorf("log = %v %q", got.Level, got.Message)
+	}
+	for name, want := range map[string]interface{}{
+		"service":      "api",
+		"req.method":   "POST",
+		"req.db.ratio": "NaN",
+		"req.inlined":  true,
+	} {
+		if got.Fields[name] != want {
+			t.Errorf("field %v = %#v, want %#v", name, got.Fields[name], want)
+		}
+	}
+	if _, ok := got.Fields["req.done"].(string); !ok {
+		t.Errorf("field req.done = %#v, want a string", got.Fields["req.done"])
+	}
+}
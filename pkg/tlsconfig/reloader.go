@@ -0,0 +1,98 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloadInterval bounds how often the certificate files are checked.
+const reloadInterval = 10 * time.Second
+
+// CertReloader serves a key pair from disk and reloads it when either file
+// changes, so certificates can be rotated without a restart.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	cr := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := cr.load(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (cr *CertReloader) load() error {
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load key pair: %w", err)
+	}
+
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.mu.Unlock()
+	return nil
+}
+
+func (cr *CertReloader) current() *tls.Certificate {
+	cr.mu.Lock()
+	check := time.Since(cr.lastCheck) >= reloadInterval
+	if check {
+		cr.lastCheck = time.Now()
+	}
+	modTime := cr.modTime
+	cr.mu.Unlock()
+
+	if check {
+		if latest, err := cr.latestModTime(); err == nil && latest.After(modTime) {
+			if err := cr.load(); err != nil {
+				log.Printf("Cannot reload certificate, keeping previous one. Error: %v", err)
+			} else {
+				log.Printf("Reloaded certificate %v", cr.certFile)
+			}
+		}
+	}
+
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert
+}
+
+// GetCertificate is used as tls.Config.GetCertificate.
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cr.current(), nil
+}
+
+// GetClientCertificate is used as tls.Config.GetClientCertificate.
+func (cr *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return cr.current(), nil
+}
@@ -0,0 +1,121 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// ServerOptions enables TLS on a listener. With ClientCAFile set, client
+// certificates are verified against it when presented.
+type ServerOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+func (o ServerOptions) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != ""
+}
+
+// Validate rejects a client CA without a server certificate and key, which
+// would otherwise leave the listener on plain HTTP.
+func (o ServerOptions) Validate() error {
+	if o.ClientCAFile != "" && (o.CertFile == "" || o.KeyFile == "") {
+		return errors.New("verifying client certificates needs a server certificate and key")
+	}
+	return nil
+}
+
+// ClientOptions configures TLS for outgoing connections, e.g. proxy to
+// backend. CertFile and KeyFile present a client certificate for mTLS.
+type ClientOptions struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %v", path)
+	}
+	return pool, nil
+}
+
+// Server builds a tls.Config that reloads its certificate when the files
+// change.
+func Server(opts ServerOptions) (*tls.Config, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("both a certificate and a key file are required for TLS")
+	}
+
+	reloader, err := NewCertReloader(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if opts.ClientCAFile != "" {
+		pool, err := loadCertPool(opts.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+// Client builds a tls.Config for outgoing connections. It returns nil when
+// no option is set so the default configuration is used.
+func Client(opts ClientOptions) (*tls.Config, error) {
+	if opts == (ClientOptions{}) {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+	if opts.CAFile != "" {
+		pool, err := loadCertPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		reloader, err := NewCertReloader(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = reloader.GetClientCertificate
+	}
+	return cfg, nil
+}
+
+// RequireClientCert rejects requests that did not present a client
+// certificate verified by the server's client CA.
+func RequireClientCert(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			http.Error(w, "Client certificate required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r, ps)
+	}
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetField returns a top-level attribute (timestamp, level, message) or a
+// structured field by name.
+func (l *LogFormat) GetField(name string) (interface{}, bool) {
+	switch name {
+	case "timestamp":
+		return l.Timestamp, !l.Timestamp.IsZero()
+	case "level":
+		return l.Level, l.Level != ""
+	case "message":
+		return l.Message, l.Message != ""
+	}
+	if l.Fields == nil {
+		return nil, false
+	}
+	value, ok := l.Fields[name]
+	return value, ok
+}
+
+// GetString is GetField formatted as a string.
+func (l *LogFormat) GetString(name string) (string, bool) {
+	value, ok := l.GetField(name)
+	if !ok {
+		return "", false
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// SetField sets a top-level attribute or a structured field. Timestamps may
+// be given as time.Time or as an RFC 3339 string.
+func (l *LogFormat) SetField(name string, value interface{}) error {
+	switch name {
+	case "timestamp":
+		switch v := value.(type) {
+		case time.Time:
+			l.Timestamp = v
+		case string:
+			ts, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return fmt.Errorf("invalid timestamp %q: %w", v, err)
+			}
+			l.Timestamp = ts
+		default:
+			return fmt.Errorf("invalid timestamp type %T", value)
+		}
+		return nil
+	case "level":
+		l.Level = fmt.Sprint(value)
+		return nil
+	case "message":
+		l.Message = fmt.Sprint(value)
+		return nil
+	}
+	if l.Fields == nil {
+		l.Fields = map[string]interface{}{}
+	}
+	l.Fields[name] = value
+	return nil
+}
+
+// DeleteField clears a top-level attribute or removes a structured field.
+func (l *LogFormat) DeleteField(name string) {
+	switch name {
+	case "timestamp":
+		l.Timestamp = time.Time{}
+	case "level":
+		l.Level = ""
+	case "message":
+		l.Message = ""
+	default:
+		delete(l.Fields, name)
+	}
+}
@@ -5,11 +5,57 @@ import (
 )
 
 type LogFormat struct {
-	Timestamp time.Time `json:"timestamp"`
-	Level     string    `json:"level"`
-	Message   string    `json:"message"`
+	Timestamp time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+	// Tenant owns the log and selects its indexes. It is set by the server
+	// from the caller's identity, never from the request body.
+	Tenant string `json:"tenant,omitempty"`
+	// SampleRate is the fraction of similar logs that was kept, so counts
+	// can be extrapolated by 1/SampleRate. Zero means not sampled.
+	SampleRate float64 `json:"sample_rate,omitempty"`
+}
+
+// IdempotencyKeyHeader names a bulk request, so that a retried batch is
+// ingested only once by the server that accepted it.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// BulkResult is returned by the bulk ingest endpoint.
+type BulkResult struct {
+	Accepted int `json:"accepted"`
+	Failed   int `json:"failed"`
 }
 
 type SearchFormat struct {
 	Query string `json:"query"`
+	// DSL is a structured query, ANDed with Query when both are set.
+	DSL *Query `json:"dsl,omitempty"`
+	// Start and End restrict the search to [Start, End) when set.
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+	// From and Size select the hits returned, 100 from the first by
+	// default. From + Size is capped by the server's result window.
+	From int `json:"from,omitempty"`
+	Size int `json:"size,omitempty"`
+	// Aggregations are computed over every match, not only the returned hits.
+	Aggregations map[string]Aggregation `json:"aggregations,omitempty"`
+	// Highlight adds fragments of the matched text to each hit.
+	Highlight *Highlight `json:"highlight,omitempty"`
+}
+
+// Export formats.
+const (
+	ExportNDJSON = "ndjson"
+	ExportCSV    = "csv"
+)
+
+// ExportFormat is a search whose every match is exported.
+type ExportFormat struct {
+	SearchFormat
+	// Format is "ndjson" (the default) or "csv".
+	Format string `json:"format,omitempty"`
+	// Columns selects the exported fields. NDJSON exports whole logs and
+	// CSV timestamp, level and message when empty.
+	Columns []string `json:"columns,omitempty"`
 }
@@ -0,0 +1,53 @@
+package types
+
+// Query is one clause of the JSON query DSL. Exactly one of its fields must
+// be set. Field names are top-level attributes or structured field names,
+// as in aggregations.
+//
+//	{"bool": {
+//	    "must": [{"term": {"field": "service", "value": "checkout-api"}}],
+//	    "must_not": [{"match": {"field": "message", "value": "healthcheck"}}]
+//	}}
+type Query struct {
+	Bool     *BoolQuery   `json:"bool,omitempty"`
+	Term     *FieldQuery  `json:"term,omitempty"`
+	Match    *FieldQuery  `json:"match,omitempty"`
+	Phrase   *FieldQuery  `json:"phrase,omitempty"`
+	Prefix   *FieldQuery  `json:"prefix,omitempty"`
+	Wildcard *FieldQuery  `json:"wildcard,omitempty"`
+	Regexp   *FieldQuery  `json:"regexp,omitempty"`
+	Range    *RangeQuery  `json:"range,omitempty"`
+	Exists   *ExistsQuery `json:"exists,omitempty"`
+}
+
+// BoolQuery matches logs matching every Must clause, none of the MustNot
+// clauses and at least MinimumShouldMatch of the Should clauses. Without
+// Must clauses one Should clause has to match.
+type BoolQuery struct {
+	Must               []Query `json:"must,omitempty"`
+	Should             []Query `json:"should,omitempty"`
+	MustNot            []Query `json:"must_not,omitempty"`
+	MinimumShouldMatch int     `json:"minimum_should_match,omitempty"`
+}
+
+// FieldQuery matches Value against Field. Term, prefix, wildcard and regexp
+// compare the indexed terms as is; match and phrase analyze Value first.
+type FieldQuery struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// RangeQuery bounds Field. The bounds are either all numbers or all RFC 3339
+// timestamps.
+type RangeQuery struct {
+	Field string      `json:"field"`
+	GT    interface{} `json:"gt,omitempty"`
+	GTE   interface{} `json:"gte,omitempty"`
+	LT    interface{} `json:"lt,omitempty"`
+	LTE   interface{} `json:"lte,omitempty"`
+}
+
+// ExistsQuery matches logs that have any value in Field.
+type ExistsQuery struct {
+	Field string `json:"field"`
+}
@@ -0,0 +1,220 @@
+package types
+
+import (
+	"strings"
+	"time"
+)
+
+// SearchResponse is the search endpoint's response. Its JSON mirrors the
+// matching parts of bleve's search result, so the shape stays stable when
+// bleve changes.
+type SearchResponse struct {
+	Hits      []SearchHit   `json:"hits"`
+	TotalHits uint64        `json:"total_hits"`
+	MaxScore  float64       `json:"max_score"`
+	Took      time.Duration `json:"took"`
+	// SkippedIndexes counts the indexes left out because they hold no logs
+	// in the requested time range.
+	SkippedIndexes int `json:"skipped_indexes"`
+	// Aggregations holds one result per requested aggregation, by name.
+	Aggregations map[string]AggregationResult `json:"aggregations,omitempty"`
+}
+
+// SearchHit is one matching log. Fields holds the stored fields as indexed,
+// with structured fields flattened to "fields.<name>".
+type SearchHit struct {
+	ID     string                 `json:"id"`
+	Index  string                 `json:"index,omitempty"`
+	Score  float64                `json:"score"`
+	Sort   []string               `json:"sort,omitempty"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	// Fragments holds the highlighted parts of matching fields, by indexed
+	// field name, when the search asked for highlighting.
+	Fragments map[string][]string `json:"fragments,omitempty"`
+}
+
+// ContextResponse is a log with the logs of the same source right before
+// and after it, both in timestamp order.
+type ContextResponse struct {
+	Log    SearchHit   `json:"log"`
+	Before []SearchHit `json:"before"`
+	After  []SearchHit `json:"after"`
+}
+
+// PipeQueryFormat runs a pipe query over the logs in [Start, End), e.g.
+// "level:error | stats count() by service | sort -count | head 10".
+type PipeQueryFormat struct {
+	Query string    `json:"query"`
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+}
+
+// PipeQueryResponse holds the rows produced by a pipe query. Structured
+// fields appear under their plain names.
+type PipeQueryResponse struct {
+	Rows []map[string]interface{} `json:"rows"`
+	// Scanned is the number of logs read from the indexes.
+	Scanned int `json:"scanned"`
+	// Truncated is set when the rows or the scanned logs hit a limit, or the
+	// query timed out and the rows cover only the logs scanned so far.
+	Truncated      bool          `json:"truncated,omitempty"`
+	SkippedIndexes int           `json:"skipped_indexes"`
+	Took           time.Duration `json:"took"`
+}
+
+// PatternsFormat mines the message templates of the logs matching Query in
+// [Start, End). With Baseline set, the same query is mined over the
+// baseline window too, e.g. the hour before a deploy, and the patterns are
+// compared.
+type PatternsFormat struct {
+	Query    string      `json:"query"`
+	Start    time.Time   `json:"start,omitempty"`
+	End      time.Time   `json:"end,omitempty"`
+	Baseline *TimeWindow `json:"baseline,omitempty"`
+	// Limit caps the patterns returned, most frequent first. 100 by default.
+	Limit int `json:"limit,omitempty"`
+}
+
+type TimeWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Pattern is a message template, with <*> for the tokens that vary, and
+// the logs sharing it.
+type Pattern struct {
+	Template   string    `json:"template"`
+	Count      int       `json:"count"`
+	ExampleIDs []string  `json:"example_ids"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	// BaselineCount is how often the pattern occurred in the baseline
+	// window, if one was given. New marks patterns missing from it.
+	BaselineCount *int `json:"baseline_count,omitempty"`
+	New           bool `json:"new,omitempty"`
+}
+
+type PatternsResponse struct {
+	Patterns []Pattern `json:"patterns"`
+	// Gone lists the baseline patterns missing from the window.
+	Gone            []Pattern `json:"gone,omitempty"`
+	Scanned         int       `json:"scanned"`
+	BaselineScanned int       `json:"baseline_scanned,omitempty"`
+	// Truncated is set when the scanned logs or the patterns hit a limit.
+	Truncated      bool          `json:"truncated,omitempty"`
+	SkippedIndexes int           `json:"skipped_indexes"`
+	Took           time.Duration `json:"took"`
+}
+
+// Highlight styles.
+const (
+	HighlightHTML = "html"
+	HighlightANSI = "ansi"
+)
+
+// Highlight asks for fragments showing where each hit matched. Fields are
+// highlighted in addition to message.
+type Highlight struct {
+	// Style is "html" (the default), which wraps matches in <mark>, or
+	// "ansi", which colors them for terminals.
+	Style  string   `json:"style,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Aggregation types.
+const (
+	AggregationTerms         = "terms"
+	AggregationDateHistogram = "date_histogram"
+	AggregationRange         = "range"
+)
+
+// Aggregation buckets the matching logs. Field is a top-level attribute or a
+// structured field name. Terms of a structured field are whole values only
+// for the fields listed in the keyword_fields config; other text fields are
+// bucketed by token.
+type Aggregation struct {
+	Type  string `json:"type"`
+	Field string `json:"field"`
+	// Size is the number of top terms returned by a terms aggregation, 10
+	// by default and at most 1000, or 50 with nested Aggregations.
+	Size int `json:"size,omitempty"`
+	// Interval of a date histogram: "auto" (the default) or a duration such
+	// as "1m", "1h" or "1d".
+	Interval string `json:"interval,omitempty"`
+	// Ranges of a range aggregation. From is inclusive, To exclusive.
+	Ranges []NumericRange `json:"ranges,omitempty"`
+	// Aggregations are computed within each bucket of a terms aggregation.
+	Aggregations map[string]Aggregation `json:"aggregations,omitempty"`
+}
+
+type NumericRange struct {
+	Name string   `json:"name,omitempty"`
+	From *float64 `json:"from,omitempty"`
+	To   *float64 `json:"to,omitempty"`
+}
+
+type AggregationResult struct {
+	Type    string   `json:"type"`
+	Field   string   `json:"field"`
+	Buckets []Bucket `json:"buckets"`
+	// Interval is the bucket width chosen for a date histogram.
+	Interval string `json:"interval,omitempty"`
+	// Other counts matches outside the returned terms, Missing matches
+	// without the field.
+	Other   int `json:"other,omitempty"`
+	Missing int `json:"missing,omitempty"`
+}
+
+// Bucket is a term, a histogram bucket starting at Key, or a named range.
+type Bucket struct {
+	Key          string                       `json:"key"`
+	Count        int                          `json:"count"`
+	Aggregations map[string]AggregationResult `json:"aggregations,omitempty"`
+}
+
+const structuredPrefix = "fields."
+
+// topLevelFields are indexed at the top of a log document; every other name
+// refers to a structured field.
+var topLevelFields = map[string]bool{
+	"timestamp":   true,
+	"level":       true,
+	"message":     true,
+	"tenant":      true,
+	"sample_rate": true,
+}
+
+// IndexField maps a field name as used in LogFormat to its indexed name,
+// e.g. "service" to "fields.service".
+func IndexField(name string) string {
+	if topLevelFields[name] || strings.HasPrefix(name, structuredPrefix) {
+		return name
+	}
+	return structuredPrefix + name
+}
+
+// Log rebuilds the LogFormat stored in the hit.
+func (h SearchHit) Log() LogFormat {
+	var logs LogFormat
+	for name, value := range h.Fields {
+		switch name {
+		case "timestamp":
+			if v, ok := value.(string); ok {
+				logs.Timestamp, _ = time.Parse(time.RFC3339Nano, v)
+			}
+		case "level":
+			logs.Level, _ = value.(string)
+		case "message":
+			logs.Message, _ = value.(string)
+		case "tenant":
+			logs.Tenant, _ = value.(string)
+		case "sample_rate":
+			logs.SampleRate, _ = value.(float64)
+		default:
+			if field, ok := strings.CutPrefix(name, structuredPrefix); ok {
+				logs.SetField(field, value)
+			}
+		}
+	}
+	return logs
+}